@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/GwentAPI/manipulator/common"
 	"github.com/GwentAPI/manipulator/models"
+	"github.com/GwentAPI/manipulator/validation"
 	"github.com/spf13/cobra"
 	"io"
 	"log"
@@ -12,6 +13,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -24,23 +26,38 @@ var downloadPath string
 var artworkCmd = &cobra.Command{
 	Use:   "artwork",
 	Short: "Download the artwork of the cards.",
-	Long:  `Download the artwork of the cards.`,
+	Long: `Download the artwork of the cards.
+
+The released cards are validated as they are read, see the validate command.
+The downloads stop at the first card with an error.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		start := time.Now()
-		result, err := parseData()
+		// The downloads start while the rest of the file is read.
+		downloadQueue := make(chan models.GwentCard)
+		cards, variations := 0, 0
+		var result *DataContainer
+		err := timePhase("download", func() (err error) {
+			wg.Add(1)
+			go startDownload(downloadQueue, &wg)
+			result, err = streamData(filePath, validation.NewValidator(validationRules()), func(key string, card models.GwentCard) error {
+				cards++
+				variations += len(card.Variations)
+				downloadQueue <- card
+				return nil
+			})
+			// startDownload is done once the queue is closed, the downloads are waited for.
+			close(downloadQueue)
+			wg.Wait()
+			return err
+		})
+		currentReport.Artwork = &artworkCounts
 		if err != nil {
-			return fmt.Errorf("Error while parsing the data: %s", err)
+			return withExitCode(EXIT_INPUT, fmt.Errorf("Error while parsing the data: %s", err))
 		}
-		dataContainer = result
-		downloadQueue := make(chan models.GwentCard)
-		wg.Add(1)
-		go startDownload(downloadQueue, &wg)
-		for k := range dataContainer.Cards {
-			downloadQueue <- dataContainer.Cards[k]
+		if err := checkFindings(filePath, result.Findings); err != nil {
+			return err
 		}
-		wg.Done()
-		close(downloadQueue)
-		wg.Wait()
+		currentReport.recordData(result, cards, variations)
 		elapsed := time.Since(start)
 		log.Printf("Finished in %s", elapsed)
 		return nil
@@ -67,7 +84,8 @@ func startDownload(queue <-chan models.GwentCard, wg *sync.WaitGroup) {
 	for card := range queue {
 		baseFileName := common.GetArtUrl(card.Name["en-US"])
 		var noVariation int = 0
-		for _, variation := range card.Variations {
+		for _, key := range card.VariationKeys() {
+			variation := card.Variations[key]
 			wg.Add(2)
 			noVariation++
 			downloadGuard <- struct{}{}
@@ -83,20 +101,43 @@ func startDownload(queue <-chan models.GwentCard, wg *sync.WaitGroup) {
 	wg.Done()
 }
 
+// Downloads of the artwork command, updated concurrently.
+var artworkCounts artworkReport
+
 func download_file(url string, fileName string, wg *sync.WaitGroup) {
 	var retry int = 0
 
+	if len(url) == 0 {
+		log.Println("Skipping: ", fileName, " Reason: no art in the card definition.")
+		atomic.AddInt64(&artworkCounts.Skipped, 1)
+		wg.Done()
+		return
+	}
+
 	for retry < MAX_RETRY {
 		if retry != 0 {
 			log.Println("Retrying ", fileName)
 		}
 		response, e := http.Get(url)
 
+		// The body of an error response is not an artwork: it is never saved.
+		if e == nil && (response.StatusCode < 200 || response.StatusCode > 299) {
+			response.Body.Close()
+			e = fmt.Errorf("HTTP status %s", response.Status)
+			// Only the server errors and the rate limiting are worth retrying.
+			if response.StatusCode < 500 && response.StatusCode != http.StatusTooManyRequests {
+				log.Println("Skipping: ", fileName, " Reason: ", e)
+				atomic.AddInt64(&artworkCounts.Failed, 1)
+				break
+			}
+		}
+
 		if e != nil {
 			log.Println("Error downloading file: ", fileName, e)
 			retry++
 			if retry == MAX_RETRY {
 				log.Println("Skipping: ", fileName, " Reason: failed too many time.")
+				atomic.AddInt64(&artworkCounts.Failed, 1)
 			}
 		} else {
 			dir, _ := filepath.Abs(downloadPath)
@@ -107,15 +148,23 @@ func download_file(url string, fileName string, wg *sync.WaitGroup) {
 			file, err := os.Create(path)
 			if err != nil {
 				log.Println("Error creating file: ", path, err)
+				atomic.AddInt64(&artworkCounts.Failed, 1)
 			} else {
 				_, err = io.Copy(file, response.Body)
 				if err != nil {
 					log.Println("Error creating file: ", err)
+					atomic.AddInt64(&artworkCounts.Failed, 1)
+				} else {
+					atomic.AddInt64(&artworkCounts.Downloaded, 1)
+				}
+				closeErr := file.Close()
+				if closeErr != nil {
+					log.Fatal(closeErr)
+				}
+				// A truncated artwork is not kept.
+				if err != nil {
+					os.Remove(path)
 				}
-			}
-			err = file.Close()
-			if err != nil {
-				log.Fatal(err)
 			}
 			response.Body.Close()
 			break
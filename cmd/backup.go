@@ -1,8 +1,18 @@
 package cmd
 
 import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
 	db "github.com/GwentAPI/manipulator/database"
 	"github.com/spf13/cobra"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 )
@@ -12,32 +22,325 @@ var backupCmd = &cobra.Command{
 	Use:   "backup",
 	Short: "Backup the monboDB databases.",
 	Long: `Backup the monboDB databases.
-mongodump will be use to backup the databases found on the system.
-The content will be gziped and archived.`,
+The collections of GwentAPI are exported by manipulator itself, in BSON
+(compatible with mongorestore) or extended JSON. With --backup-engine
+mongodump, mongodump will be use to backup the databases found on the
+system instead. The content will be gziped and archived.
+
+The backups are created under the folder given by --backup-dir. If a
+retention policy is configured, older backups are pruned afterward.`,
+	PersistentPreRunE: noInputRequired,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		flattenedHost := strings.Join(mongoDBAuthentication.Host[:], ",")
-		if len(mongoDBAuthentication.Username) > 0 {
-			if err := backupWithAuthentication(flattenedHost, mongoDBAuthentication.Username, mongoDBAuthentication.Password, mongoDBAuthentication.AuthenticationDatabase, mongoDBAuthentication.UseSSL); err != nil {
-				return err
-			}
-			return nil
+		if err := resolveMongoSettings(cmd); err != nil {
+			return err
 		}
-		if err := backupDb(flattenedHost); err != nil {
+		err := timePhase("backup", func() error {
+			_, err := createBackup("")
 			return err
+		})
+		if err != nil {
+			return withExitCode(EXIT_BACKUP, err)
 		}
+		return timePhase("retention", func() error {
+			return pruneBackups(false)
+		})
+	},
+}
+
+// backupListCmd represents the backup list command
+var backupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the backups.",
+	Long: `List the backups found in the backup folder with their size,
+collections and the content of their manifest if they have one.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printBackups()
+	},
+}
+
+var verifyDb string
+
+// backupVerifyCmd represents the backup verify command
+var backupVerifyCmd = &cobra.Command{
+	Use:   "verify <backup>",
+	Short: "Verify that a backup is usable.",
+	Long: `Verify that a backup is usable.
+
+Every collection of GwentAPI must have a readable gziped BSON file and
+metadata file. If the backup has a manifest, the number of documents of
+each collection must match the manifest.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		snapshot, err := findBackup(args[0])
+		if err != nil {
+			return err
+		}
+		problems, err := verifyBackup(snapshot, verifyDb)
+		if err != nil {
+			return err
+		}
+		if len(problems) > 0 {
+			for _, problem := range problems {
+				log.Println("Problem: ", problem)
+			}
+			return fmt.Errorf("Backup %s is not valid: %d problem(s) found", snapshot.Name, len(problems))
+		}
+		log.Println("Backup ", snapshot.Name, " is valid.")
 		return nil
 	},
 }
 
+var pruneDryRun bool
+
+// backupPruneCmd represents the backup prune command
+var backupPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete the backups not kept by the retention policy.",
+	Long: `Delete the backups not kept by the retention policy.
+
+The retention policy is set with the --keep-last, --keep-daily and
+--keep-weekly flags or the backup.retention config keys. The most
+recent backup is never deleted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if currentRetentionPolicy().isEmpty() {
+			return errors.New("No retention policy configured")
+		}
+		return pruneBackups(pruneDryRun)
+	},
+}
+
 func init() {
 	RootCmd.AddCommand(backupCmd)
-	mongoDBAuthentication = db.MongoConnectionSettings{
-		Timeout: 15 * time.Second,
-	}
-	backupCmd.Flags().StringVar(&mongoDBAuthentication.Username, "u", "", "Username used for mongoDB authentication.")
-	backupCmd.Flags().StringVar(&mongoDBAuthentication.AuthenticationDatabase, "authenticationDatabase", "", "Authentication database for mongoDB.")
-	backupCmd.Flags().StringVar(&mongoDBAuthentication.Password, "p", "", "User password for mongoDB authentication.")
-	backupCmd.Flags().StringSliceVar(&mongoDBAuthentication.Host, "host", []string{"localhost"}, "Address to a remote mongos.")
-	// TODO: Don't use global var
-	backupCmd.Flags().BoolVar(&mongoDBAuthentication.UseSSL, "ssl", false, "Set to true if you require SSL to connect to the database")
+	backupCmd.AddCommand(backupListCmd)
+	backupCmd.AddCommand(backupVerifyCmd)
+	backupCmd.AddCommand(backupPruneCmd)
+	backupVerifyCmd.Flags().StringVar(&verifyDb, "db", "", "Database of the backup to verify (default the database of the manifest).")
+	backupPruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "Only show the backups that would be deleted.")
+	backupCmd.Flags().AddFlagSet(mongoFlags)
+}
+
+// printBackups lists every backup with its status, the failed ones included.
+func printBackups() error {
+	snapshots, err := listBackups(true)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		fmt.Println("No backup found in", backupFolder())
+		return nil
+	}
+	for _, snapshot := range snapshots {
+		size, err := folderSize(snapshot.Path)
+		if err != nil {
+			return err
+		}
+		manifest, err := readManifest(snapshot.Path)
+		status := "no manifest"
+		if err != nil {
+			status = fmt.Sprintf("unreadable manifest (%s)", err)
+		} else if manifest != nil {
+			status = manifest.Status
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\n", snapshot.Name, snapshot.Time.Format(time.RFC1123), formatSize(size), status)
+		if manifest != nil {
+			fmt.Printf("    Hosts: %s\n", strings.Join(manifest.Hosts, ","))
+			if len(manifest.Database) > 0 {
+				fmt.Printf("    Database: %s\n", manifest.Database)
+			}
+			fmt.Printf("    Manipulator version: %s\n", manifest.ManipulatorVersion)
+			if manifest.Input != nil {
+				fmt.Printf("    Input: %s (sha256 %s)\n", manifest.Input.Path, manifest.Input.SHA256)
+			}
+			if len(manifest.Error) > 0 {
+				fmt.Printf("    Error: %s\n", manifest.Error)
+			}
+		}
+		collections, err := backupCollections(snapshot.Path)
+		if err != nil {
+			return err
+		}
+		for _, collection := range collections {
+			if manifest != nil {
+				if count, ok := manifest.Collections[collection]; ok {
+					fmt.Printf("    %s: %d documents\n", collection, count)
+					continue
+				}
+			}
+			fmt.Printf("    %s\n", collection)
+		}
+	}
+	return nil
+}
+
+// backupCollections returns the collections (<database>.<collection>) found in a backup.
+func backupCollections(folder string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(folder, "*", "*.gz"))
+	if err != nil {
+		return nil, err
+	}
+	var collections []string
+	for _, path := range files {
+		name := filepath.Base(path)
+		if strings.HasSuffix(name, ".metadata.json.gz") {
+			continue
+		}
+		name = strings.TrimSuffix(strings.TrimSuffix(name, ".bson.gz"), ".json.gz")
+		collections = append(collections, filepath.Base(filepath.Dir(path))+"."+name)
+	}
+	sort.Strings(collections)
+	return collections, nil
+}
+
+func folderSize(folder string) (int64, error) {
+	var size int64
+	err := filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// verifyBackup checks every collection of GwentAPI in the backup and returns the problems found.
+// database overrides the database found in the manifest.
+func verifyBackup(snapshot backupSnapshot, database string) ([]string, error) {
+	manifest, err := readManifest(snapshot.Path)
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		log.Println("The backup doesn't have a manifest, document counts won't be verified.")
+	} else if manifest.Status != BACKUP_STATUS_SUCCESS {
+		return []string{fmt.Sprintf("the manifest reports a failed backup: %s", manifest.Error)}, nil
+	}
+	if len(database) == 0 {
+		database, err = findBackupDatabase(snapshot, manifest)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var problems []string
+	for _, collection := range db.GwentCollections {
+		prefix := filepath.Join(snapshot.Path, database, collection)
+		var count int
+		if optionalBackupCollection(snapshot, manifest, database, collection) {
+			log.Printf("%s: not in the backup", collection)
+			continue
+		}
+		if manifest != nil && manifest.Format == db.EXPORT_FORMAT_JSON {
+			count, err = readGzipJSONDocuments(prefix + ".json.gz")
+		} else {
+			count, err = readGzipBSONDocuments(prefix+".bson.gz", func(document []byte) error {
+				return bson.Unmarshal(document, &bson.M{})
+			})
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: unreadable data: %s", collection, err))
+			continue
+		}
+		if err := readGzipJSON(prefix + ".metadata.json.gz"); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: unreadable metadata: %s", collection, err))
+			continue
+		}
+		if manifest != nil {
+			expected, ok := manifest.Collections[database+"."+collection]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing from the manifest", collection))
+				continue
+			}
+			if expected != count {
+				problems = append(problems, fmt.Sprintf("%s: %d documents found, %d expected", collection, count, expected))
+				continue
+			}
+		}
+		log.Printf("%s: %d documents", collection, count)
+	}
+	return problems, nil
+}
+
+// optionalBackupCollection returns true if the backup doesn't have the alias collection, which
+// older backups, and the backups of a database that doesn't have any alias yet, don't have.
+func optionalBackupCollection(snapshot backupSnapshot, manifest *BackupManifest, database, collection string) bool {
+	if collection != db.ALIAS_COLLECTION {
+		return false
+	}
+	if manifest != nil {
+		_, ok := manifest.Collections[database+"."+collection]
+		return !ok
+	}
+	files, err := filepath.Glob(filepath.Join(snapshot.Path, database, collection+".*"))
+	return err == nil && len(files) == 0
+}
+
+// findBackupDatabase returns the database of the manifest, or the only database of the backup
+// that contains GwentAPI cards.
+func findBackupDatabase(snapshot backupSnapshot, manifest *BackupManifest) (string, error) {
+	if manifest != nil && len(manifest.Database) > 0 {
+		return manifest.Database, nil
+	}
+	files, err := filepath.Glob(filepath.Join(snapshot.Path, "*", "cards.bson.gz"))
+	if err != nil {
+		return "", err
+	}
+	if len(files) != 1 {
+		return "", fmt.Errorf("Can't determine the database of backup %s, use --db", snapshot.Name)
+	}
+	return filepath.Base(filepath.Dir(files[0])), nil
+}
+
+func readGzipJSON(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	var content map[string]interface{}
+	return json.NewDecoder(reader).Decode(&content)
+}
+
+// readGzipJSONDocuments returns the number of documents of a gziped file of JSON documents, one per line.
+func readGzipJSONDocuments(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	count := 0
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if !json.Valid(scanner.Bytes()) {
+			return count, fmt.Errorf("invalid JSON document on line %d", count+1)
+		}
+		count++
+	}
+	return count, scanner.Err()
 }
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/GwentAPI/manipulator/compare"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+	"text/template"
+)
+
+const (
+	CHANGELOG_FORMAT_MARKDOWN string = "markdown"
+	CHANGELOG_FORMAT_HTML     string = "html"
+)
+
+const changelogMarkdownTemplate = `# Patch notes
+{{range .Factions}}
+## {{.Faction}}
+{{if .New}}
+### New cards
+{{range .New}}
+* {{.Name}}{{end}}
+{{end}}{{if .Buffs}}
+### Buffs
+{{range .Buffs}}
+* {{.Name}}: {{.Old}} → {{.New}}{{end}}
+{{end}}{{if .Nerfs}}
+### Nerfs
+{{range .Nerfs}}
+* {{.Name}}: {{.Old}} → {{.New}}{{end}}
+{{end}}{{if .Abilities}}
+### Ability changes
+{{range .Abilities}}
+* {{.Name}}: {{range $i, $word := .Words}}{{if $i}} {{end}}{{if eq $word.Op "insert"}}**{{$word.Text}}**{{else if eq $word.Op "delete"}}~~{{$word.Text}}~~{{else}}{{$word.Text}}{{end}}{{end}}{{end}}
+{{end}}{{if .Removed}}
+### Removed cards
+{{range .Removed}}
+* {{.Name}}{{end}}
+{{end}}{{end}}`
+
+const changelogHTMLTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Patch notes</title>
+</head>
+<body>
+<h1>Patch notes</h1>
+{{range .Factions}}
+<h2>{{html .Faction}}</h2>
+{{if .New}}<h3>New cards</h3>
+<ul>{{range .New}}
+<li>{{html .Name}}</li>{{end}}
+</ul>
+{{end}}{{if .Buffs}}<h3>Buffs</h3>
+<ul>{{range .Buffs}}
+<li>{{html .Name}}: {{.Old}} &rarr; {{.New}}</li>{{end}}
+</ul>
+{{end}}{{if .Nerfs}}<h3>Nerfs</h3>
+<ul>{{range .Nerfs}}
+<li>{{html .Name}}: {{.Old}} &rarr; {{.New}}</li>{{end}}
+</ul>
+{{end}}{{if .Abilities}}<h3>Ability changes</h3>
+<ul>{{range .Abilities}}
+<li>{{html .Name}}: {{range $i, $word := .Words}}{{if $i}} {{end}}{{if eq $word.Op "insert"}}<ins>{{html $word.Text}}</ins>{{else if eq $word.Op "delete"}}<del>{{html $word.Text}}</del>{{else}}{{html $word.Text}}{{end}}{{end}}</li>{{end}}
+</ul>
+{{end}}{{if .Removed}}<h3>Removed cards</h3>
+<ul>{{range .Removed}}
+<li>{{html .Name}}</li>{{end}}
+</ul>
+{{end}}{{end}}
+</body>
+</html>
+`
+
+var changelogTemplates = map[string]string{
+	CHANGELOG_FORMAT_MARKDOWN: changelogMarkdownTemplate,
+	CHANGELOG_FORMAT_HTML:     changelogHTMLTemplate,
+}
+
+var changelogOld string
+var changelogNew string
+var changelogFormat string
+var changelogTemplate string
+
+// changelogCmd represents the changelog command
+var changelogCmd = &cobra.Command{
+	Use:   "changelog",
+	Short: "Generate the patch notes between two card definition files.",
+	Long: `Generate the patch notes between two card definition files.
+
+The notes are grouped by faction: new cards, buffs and nerfs of the strength,
+changes of the English ability text (word by word) and removed cards.
+
+They are rendered in Markdown or HTML (--format), or with a Go text/template
+given by --template. The template receives a compare.Changelog.`,
+	PersistentPreRunE: noInputRequired,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(changelogOld) == 0 || len(changelogNew) == 0 {
+			return withExitCode(EXIT_INPUT, errors.New("Both --old and --new are required"))
+		}
+		text, ok := changelogTemplates[changelogFormat]
+		if len(changelogTemplate) > 0 {
+			data, err := ioutil.ReadFile(changelogTemplate)
+			if err != nil {
+				return fmt.Errorf("Error while reading the template: %s", err)
+			}
+			text, ok = string(data), true
+		}
+		if !ok {
+			return fmt.Errorf("Unknown format: %s", changelogFormat)
+		}
+		tmpl, err := template.New("changelog").Parse(text)
+		if err != nil {
+			return fmt.Errorf("Invalid template: %s", err)
+		}
+		old, err := parseData(changelogOld, nil)
+		if err != nil {
+			return withExitCode(EXIT_INPUT, fmt.Errorf("Error while parsing %s: %s", changelogOld, err))
+		}
+		new, err := parseData(changelogNew, nil)
+		if err != nil {
+			return withExitCode(EXIT_INPUT, fmt.Errorf("Error while parsing %s: %s", changelogNew, err))
+		}
+		return tmpl.Execute(os.Stdout, compare.NewChangelog(old.Cards, new.Cards))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(changelogCmd)
+
+	changelogCmd.Flags().StringVar(&changelogOld, "old", "", "Previous card definition file.")
+	changelogCmd.Flags().StringVar(&changelogNew, "new", "", "New card definition file.")
+	changelogCmd.Flags().StringVar(&changelogFormat, "format", CHANGELOG_FORMAT_MARKDOWN, "Format of the default template: markdown or html.")
+	changelogCmd.Flags().StringVar(&changelogTemplate, "template", "", "Go text/template file used instead of the default template.")
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/GwentAPI/manipulator/compare"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestChangelogTemplates(t *testing.T) {
+	changelog := compare.Changelog{Factions: []compare.FactionNotes{{
+		Faction: "Monsters",
+		New:     []compare.Card{{Name: "Arachas"}},
+		Buffs:   []compare.StrengthChange{{Card: compare.Card{Name: "Ghoul"}, Old: 3, New: 4}},
+		Abilities: []compare.AbilityChange{{
+			Card:  compare.Card{Name: "Nekker"},
+			Words: compare.DiffWords("Deal 3 damage. Spying.", "Deal 4 damage. Spying."),
+		}},
+	}}}
+	tests := []struct {
+		format   string
+		expected []string
+	}{
+		{
+			format: CHANGELOG_FORMAT_MARKDOWN,
+			expected: []string{
+				"## Monsters",
+				"### New cards\n\n* Arachas\n",
+				"### Buffs\n\n* Ghoul: 3 → 4\n",
+				"* Nekker: Deal ~~3~~ **4** damage. Spying.\n",
+			},
+		},
+		{
+			format: CHANGELOG_FORMAT_HTML,
+			expected: []string{
+				"<h2>Monsters</h2>",
+				"<li>Arachas</li>",
+				"<li>Ghoul: 3 &rarr; 4</li>",
+				"<li>Nekker: Deal <del>3</del> <ins>4</ins> damage. Spying.</li>",
+			},
+		},
+	}
+	for _, test := range tests {
+		tmpl, err := template.New("changelog").Parse(changelogTemplates[test.format])
+		if err != nil {
+			t.Errorf("%s: invalid template: %s", test.format, err)
+			continue
+		}
+		var output bytes.Buffer
+		if err := tmpl.Execute(&output, changelog); err != nil {
+			t.Errorf("%s: %s", test.format, err)
+			continue
+		}
+		for _, expected := range test.expected {
+			if !strings.Contains(output.String(), expected) {
+				t.Errorf("%s: %q not found in:\n%s", test.format, expected, output.String())
+			}
+		}
+	}
+}
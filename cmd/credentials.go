@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	db "github.com/GwentAPI/manipulator/database"
+	"github.com/spf13/viper"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Environment variable that can hold the password used for mongoDB authentication.
+const PASSWORD_ENV string = "MANIPULATOR_MONGO_PASSWORD"
+
+// resolvePassword sets the password used for mongoDB authentication when a username is
+// given without a password. The password is read, in order, from the file given by
+// --passwordFile, from the MANIPULATOR_MONGO_PASSWORD environment variable (or the
+// mongo.password config key) or from an interactive prompt.
+// Nothing is resolved for the mechanisms that don't authenticate with a password.
+func resolvePassword() error {
+	if len(mongoDBAuthentication.Username) == 0 || !mechanismUsesPassword(mongoDBAuthentication.AuthMechanism) {
+		return nil
+	}
+	if len(mongoDBAuthentication.Password) > 0 {
+		log.Println("Warning: a password given on the command line is visible to the other users of the system, prefer --passwordFile or " + PASSWORD_ENV + ".")
+		return nil
+	}
+	if len(passwordFile) > 0 {
+		content, err := ioutil.ReadFile(passwordFile)
+		if err != nil {
+			return fmt.Errorf("Error while reading the password file: %s", err)
+		}
+		mongoDBAuthentication.Password = strings.TrimRight(string(content), "\r\n")
+		return nil
+	}
+	if password := viper.GetString("mongo.password"); len(password) > 0 {
+		mongoDBAuthentication.Password = password
+		return nil
+	}
+	password, err := promptPassword(fmt.Sprintf("Password for %s: ", mongoDBAuthentication.Username))
+	if err != nil {
+		return err
+	}
+	mongoDBAuthentication.Password = password
+	return nil
+}
+
+// mechanismUsesPassword returns false for the authentication mechanisms that don't take a password,
+// x509 authenticates with the client certificate.
+func mechanismUsesPassword(mechanism string) bool {
+	return !strings.EqualFold(mechanism, db.MECHANISM_X509)
+}
+
+// promptPassword reads a password from the terminal without echoing it when possible.
+func promptPassword(prompt string) (string, error) {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return "", errors.New("A password is required: use --passwordFile or " + PASSWORD_ENV)
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	if runtime.GOOS != "windows" {
+		if err := setTerminalEcho(false); err == nil {
+			defer setTerminalEcho(true)
+		}
+	}
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(password, "\r\n"), nil
+}
+
+func setTerminalEcho(enabled bool) error {
+	mode := "-echo"
+	if enabled {
+		mode = "echo"
+	}
+	cmd := exec.Command("stty", mode)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
+// mongoToolArgs returns the connection arguments of mongodump and mongorestore.
+// The password is never put on the command line: it is written to a temporary
+// config file only readable by the current user and given to the tool with --config.
+// cleanup must be called once the tool exited to delete the file.
+func mongoToolArgs() (args []string, cleanup func(), err error) {
+	cleanup = func() {}
+	host := strings.Join(mongoDBAuthentication.Host[:], ",")
+	if len(mongoDBAuthentication.ReplicaSet) > 0 {
+		host = mongoDBAuthentication.ReplicaSet + "/" + host
+	}
+	args = []string{"--host", host}
+	if len(mongoDBAuthentication.Username) > 0 {
+		args = append(args, "--username", mongoDBAuthentication.Username)
+	}
+	if len(mongoDBAuthentication.AuthenticationDatabase) > 0 {
+		args = append(args, "--authenticationDatabase", mongoDBAuthentication.AuthenticationDatabase)
+	}
+	if len(mongoDBAuthentication.AuthMechanism) > 0 {
+		args = append(args, "--authenticationMechanism", mongoDBAuthentication.AuthMechanism)
+	}
+	if len(mongoDBAuthentication.ReadPreference) > 0 {
+		args = append(args, "--readPreference", mongoDBAuthentication.ReadPreference)
+	}
+	if len(mongoDBAuthentication.Password) > 0 {
+		configFile, err := writeToolConfig(mongoDBAuthentication.Password)
+		if err != nil {
+			return nil, cleanup, fmt.Errorf("Error while writing the credentials: %s", err)
+		}
+		cleanup = func() {
+			os.Remove(configFile)
+		}
+		args = append(args, "--config", configFile)
+	}
+	if mongoDBAuthentication.UseSSL {
+		args = append(args, "--ssl")
+	}
+	if len(mongoDBAuthentication.TLSCAFile) > 0 {
+		args = append(args, "--sslCAFile", mongoDBAuthentication.TLSCAFile)
+	}
+	if len(mongoDBAuthentication.TLSCertificateKeyFile) > 0 {
+		args = append(args, "--sslPEMKeyFile", mongoDBAuthentication.TLSCertificateKeyFile)
+	}
+	if mongoDBAuthentication.TLSInsecure {
+		args = append(args, "--sslAllowInvalidCertificates", "--sslAllowInvalidHostnames")
+	}
+	if len(mongoDBAuthentication.TLSServerName) > 0 {
+		log.Println("Warning: --tlsServerName isn't supported by the mongoDB tools and is ignored by them.")
+	}
+	return args, cleanup, nil
+}
+
+// writeToolConfig writes the YAML config file holding the password and returns its path.
+func writeToolConfig(password string) (string, error) {
+	// ioutil.TempFile creates the file with the 0600 permissions.
+	file, err := ioutil.TempFile("", "manipulator-")
+	if err != nil {
+		return "", err
+	}
+	// A JSON string is a valid YAML double-quoted scalar.
+	quoted, err := json.Marshal(password)
+	if err == nil {
+		_, err = fmt.Fprintf(file, "password: %s\n", quoted)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
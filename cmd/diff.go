@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/GwentAPI/manipulator/compare"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	DIFF_FORMAT_TEXT     string = "text"
+	DIFF_FORMAT_JSON     string = "json"
+	DIFF_FORMAT_MARKDOWN string = "markdown"
+)
+
+var diffOld string
+var diffNew string
+var diffFormat string
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare two card definition files.",
+	Long: `Compare two card definition files without touching the database.
+
+The released cards of both files are matched by their key and the added,
+removed and modified cards are reported: the texts locale by locale, the
+strength, faction, type, positions, loyalties and categories, and the rarity,
+craft, mill and art of the variations.
+
+The report is written as text, JSON or Markdown (--format).`,
+	PersistentPreRunE: noInputRequired,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(diffOld) == 0 || len(diffNew) == 0 {
+			return withExitCode(EXIT_INPUT, errors.New("Both --old and --new are required"))
+		}
+		write, ok := diffWriters[diffFormat]
+		if !ok {
+			return fmt.Errorf("Unknown format: %s", diffFormat)
+		}
+		old, err := parseData(diffOld, nil)
+		if err != nil {
+			return withExitCode(EXIT_INPUT, fmt.Errorf("Error while parsing %s: %s", diffOld, err))
+		}
+		new, err := parseData(diffNew, nil)
+		if err != nil {
+			return withExitCode(EXIT_INPUT, fmt.Errorf("Error while parsing %s: %s", diffNew, err))
+		}
+		return write(os.Stdout, compare.Cards(old.Cards, new.Cards))
+	},
+}
+
+var diffWriters = map[string]func(io.Writer, compare.Report) error{
+	DIFF_FORMAT_TEXT:     writeDiffText,
+	DIFF_FORMAT_JSON:     writeDiffJSON,
+	DIFF_FORMAT_MARKDOWN: writeDiffMarkdown,
+}
+
+func init() {
+	RootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringVar(&diffOld, "old", "", "Previous card definition file.")
+	diffCmd.Flags().StringVar(&diffNew, "new", "", "New card definition file.")
+	diffCmd.Flags().StringVar(&diffFormat, "format", DIFF_FORMAT_TEXT, "Output format: text, json or markdown.")
+}
+
+func writeDiffText(w io.Writer, report compare.Report) error {
+	fmt.Fprintf(w, "%d added, %d removed, %d modified\n", len(report.Added), len(report.Removed), len(report.Modified))
+	for _, card := range report.Added {
+		fmt.Fprintf(w, "+ %s (%s)\n", card.Name, card.Faction)
+	}
+	for _, card := range report.Removed {
+		fmt.Fprintf(w, "- %s (%s)\n", card.Name, card.Faction)
+	}
+	for _, card := range report.Modified {
+		fmt.Fprintf(w, "~ %s (%s)\n", card.Name, card.Faction)
+		for _, change := range card.Changes {
+			fmt.Fprintf(w, "    %s: %s -> %s\n", change.Field, formatDiffValue(change.Old), formatDiffValue(change.New))
+		}
+	}
+	return nil
+}
+
+func writeDiffJSON(w io.Writer, report compare.Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+func writeDiffMarkdown(w io.Writer, report compare.Report) error {
+	fmt.Fprintf(w, "# Card changes\n\n%d added, %d removed, %d modified.\n", len(report.Added), len(report.Removed), len(report.Modified))
+	for _, section := range []struct {
+		title string
+		cards []compare.Card
+	}{
+		{"Added cards", report.Added},
+		{"Removed cards", report.Removed},
+	} {
+		if len(section.cards) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n## %s\n\n", section.title)
+		for _, card := range section.cards {
+			fmt.Fprintf(w, "* %s (%s)\n", escapeMarkdown(card.Name), escapeMarkdown(card.Faction))
+		}
+	}
+	if len(report.Modified) > 0 {
+		fmt.Fprint(w, "\n## Modified cards\n")
+	}
+	for _, card := range report.Modified {
+		fmt.Fprintf(w, "\n### %s (%s)\n\n| Field | Old | New |\n| --- | --- | --- |\n", escapeMarkdown(card.Name), escapeMarkdown(card.Faction))
+		for _, change := range card.Changes {
+			fmt.Fprintf(w, "| %s | %s | %s |\n", change.Field, escapeMarkdown(formatDiffValue(change.Old)), escapeMarkdown(formatDiffValue(change.New)))
+		}
+	}
+	return nil
+}
+
+var markdownEscaper = strings.NewReplacer("|", "\\|", "*", "\\*", "_", "\\_", "\n", "<br>")
+
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	db "github.com/GwentAPI/manipulator/database"
+	"gopkg.in/mgo.v2"
+	"log"
+)
+
+// dryRunUpdate reports what updateDb would change without writing anything.
+func dryRunUpdate(container *DataContainer) error {
+	log.Println("Dry run: nothing will be written and no backup will be created.")
+	log.Println("Attempting to establish mongoDB session...")
+	session, err := repo.CreateSession(mongoDBAuthentication)
+	if err != nil {
+		return withExitCode(EXIT_CONNECTION, fmt.Errorf("Failed to establish mongoDB connection: %s", err))
+	}
+	defer session.Close()
+	// The dry run only reads: unless a read preference was given, the reads may go to a secondary.
+	if len(mongoDBAuthentication.ReadPreference) == 0 {
+		session.SetMode(mgo.SecondaryPreferred, true)
+	}
+	database := session.DB("")
+
+	var diffs []db.CollectionDiff
+	for _, generic := range container.GenericCollections() {
+		diff, err := repo.DiffGenericCollection(database, generic.Name, generic.Values)
+		if err != nil {
+			return err
+		}
+		diffs = append(diffs, diff)
+	}
+	diff, err := repo.DiffCards(database, "cards", container.Cards)
+	if err != nil {
+		return err
+	}
+	diffs = append(diffs, diff)
+	diff, err = repo.DiffVariations(database, "variations", "cards", container.Cards)
+	if err != nil {
+		return err
+	}
+	diffs = append(diffs, diff)
+
+	for _, diff := range diffs {
+		printCollectionDiff(diff)
+	}
+	renames, err := repo.DetectRenames(database, "cards", container.Cards)
+	if err != nil {
+		return err
+	}
+	if len(renames) > 0 {
+		printRenames(renames)
+	}
+	return nil
+}
+
+func printCollectionDiff(diff db.CollectionDiff) {
+	fmt.Printf("%s: %d added, %d modified, %d unchanged\n", diff.Collection, len(diff.Added), len(diff.Modified), diff.Unchanged)
+	for _, name := range diff.Added {
+		fmt.Printf("  + %s\n", name)
+	}
+	for _, document := range diff.Modified {
+		fmt.Printf("  ~ %s\n", document.Name)
+		for _, change := range document.Changes {
+			fmt.Printf("      %s: %s -> %s\n", change.Field, formatDiffValue(change.Old), formatDiffValue(change.New))
+		}
+	}
+	if len(diff.Stale) > 0 {
+		if updatePrune {
+			fmt.Printf("  %d document(s) would be removed by --prune:\n", len(diff.Stale))
+			for _, name := range diff.Stale {
+				fmt.Printf("  - %s\n", name)
+			}
+		} else {
+			fmt.Printf("  %d document(s) not in the input file, use --prune to remove them:\n", len(diff.Stale))
+			for _, name := range diff.Stale {
+				fmt.Printf("  ? %s\n", name)
+			}
+		}
+	}
+}
+
+func formatDiffValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "(none)"
+	case string:
+		return fmt.Sprintf("%q", v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
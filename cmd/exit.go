@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	db "github.com/GwentAPI/manipulator/database"
+)
+
+// Exit codes of manipulator.
+const (
+	EXIT_ERROR      int = 1
+	EXIT_INPUT      int = 2
+	EXIT_CONNECTION int = 3
+	EXIT_BACKUP     int = 4
+	EXIT_DATABASE   int = 5
+)
+
+// commandError is an error with the exit code of the process.
+type commandError struct {
+	code int
+	err  error
+}
+
+func (e *commandError) Error() string {
+	return e.err.Error()
+}
+
+// withExitCode sets the exit code of err, unless it already has one.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*commandError); ok {
+		return err
+	}
+	return &commandError{code: code, err: err}
+}
+
+func exitCode(err error) int {
+	switch e := err.(type) {
+	case *commandError:
+		return e.code
+	case *db.RepositoryError:
+		return EXIT_DATABASE
+	default:
+		return EXIT_ERROR
+	}
+}
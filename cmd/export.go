@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"fmt"
+	db "github.com/GwentAPI/manipulator/database"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+const (
+	BACKUP_ENGINE_NATIVE    string = "native"
+	BACKUP_ENGINE_MONGODUMP string = "mongodump"
+)
+
+// backupNative exports every collection of GwentAPI without relying on mongodump.
+// The layout of the backup is the same as mongodump: <out>/<database>/<collection>.bson.gz
+// and <collection>.metadata.json.gz. With the json format, the data files are <collection>.json.gz.
+// It returns the name of the database that was exported and the number of documents per collection.
+func backupNative(out, format string) (string, map[string]int, error) {
+	session, err := repo.CreateSession(mongoDBAuthentication)
+	if err != nil {
+		return "", nil, fmt.Errorf("Failed to establish mongoDB connection: %s", err)
+	}
+	defer session.Close()
+	database := session.DB("")
+
+	folder := filepath.Join(out, database.Name)
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return database.Name, nil, err
+	}
+	counts := make(map[string]int)
+	for _, name := range db.GwentCollections {
+		collection := database.C(name)
+		count, err := exportGzip(filepath.Join(folder, name+"."+format+".gz"), func(w *gzip.Writer) (int, error) {
+			return repo.ExportCollection(collection, format, w)
+		})
+		if err != nil {
+			return database.Name, counts, fmt.Errorf("Error while exporting %s: %s", name, err)
+		}
+		_, err = exportGzip(filepath.Join(folder, name+".metadata.json.gz"), func(w *gzip.Writer) (int, error) {
+			return 0, repo.ExportMetadata(collection, w)
+		})
+		if err != nil {
+			return database.Name, counts, fmt.Errorf("Error while exporting the metadata of %s: %s", name, err)
+		}
+		counts[database.Name+"."+name] = count
+	}
+	log.Println("Database backup created.")
+	return database.Name, counts, nil
+}
+
+func exportGzip(path string, export func(w *gzip.Writer) (int, error)) (int, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	writer := gzip.NewWriter(file)
+	count, err := export(writer)
+	if err != nil {
+		return count, err
+	}
+	if err := writer.Close(); err != nil {
+		return count, err
+	}
+	return count, file.Close()
+}
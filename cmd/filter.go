@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/GwentAPI/manipulator/models"
+	"github.com/spf13/viper"
+	"log"
+	"sort"
+)
+
+// releaseFilter decides which cards and variations of the definition file are released.
+type releaseFilter struct {
+	// Availabilities of the variations that are live.
+	LiveSets              []string
+	IncludeNonCollectible bool
+	// IngameIds of the cards always kept, released or not.
+	Include []string
+	// IngameIds of the cards always removed.
+	Exclude []string
+}
+
+// filteredItem is a card or a variation removed by the release filter.
+type filteredItem struct {
+	Card      string `json:"card"`
+	IngameId  string `json:"ingameId"`
+	Variation string `json:"variation,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+func currentReleaseFilter() releaseFilter {
+	return releaseFilter{
+		LiveSets:              viper.GetStringSlice("filter.liveSets"),
+		IncludeNonCollectible: viper.GetBool("filter.includeNonCollectible"),
+		Include:               viper.GetStringSlice("filter.include"),
+		Exclude:               viper.GetStringSlice("filter.exclude"),
+	}
+}
+
+// card removes the variations of a card that are not released and returns what was removed.
+// The card itself isn't kept when none of its variations is left.
+func (f releaseFilter) card(card *models.GwentCard) (kept bool, filtered []filteredItem) {
+	item := filteredItem{Card: card.Name["en-US"], IngameId: card.IngameId}
+	if _, ok := stringSet(f.Exclude)[card.IngameId]; ok && len(card.IngameId) > 0 {
+		item.Reason = "excluded by IngameId"
+		return false, append(filtered, item)
+	}
+	if _, ok := stringSet(f.Include)[card.IngameId]; ok && len(card.IngameId) > 0 {
+		return true, nil
+	}
+	if !card.Released {
+		item.Reason = "not released"
+		return false, append(filtered, item)
+	}
+	liveSets := stringSet(f.LiveSets)
+	for variationKey, variation := range card.Variations {
+		item := item
+		item.Variation = variationKey
+		if _, ok := liveSets[variation.Availability]; !ok {
+			item.Reason = fmt.Sprintf("set %s is not live", variation.Availability)
+		} else if !variation.Collectible && !f.IncludeNonCollectible {
+			item.Reason = "not collectible"
+		} else {
+			continue
+		}
+		filtered = append(filtered, item)
+		delete(card.Variations, variationKey)
+	}
+	// Without variation, there's no associated art, rarity, etc.
+	if len(card.Variations) == 0 {
+		item.Reason = "no released variation"
+		return false, append(filtered, item)
+	}
+	return true, filtered
+}
+
+func sortFilteredItems(filtered []filteredItem) {
+	sort.Slice(filtered, func(i, j int) bool {
+		if filtered[i].Card != filtered[j].Card {
+			return filtered[i].Card < filtered[j].Card
+		}
+		return filtered[i].Variation < filtered[j].Variation
+	})
+}
+
+// logFilterReport logs the number of items filtered by reason, and every item with --filter-report.
+func logFilterReport(filtered []filteredItem) {
+	counts := make(map[string]int)
+	for _, item := range filtered {
+		counts[item.Reason]++
+		if viper.GetBool("filter.report") {
+			if len(item.Variation) > 0 {
+				log.Printf("Filtered variation %s of %s (IngameId %s): %s", item.Variation, item.Card, item.IngameId, item.Reason)
+			} else {
+				log.Printf("Filtered card %s (IngameId %s): %s", item.Card, item.IngameId, item.Reason)
+			}
+		}
+	}
+	reasons := make([]string, 0, len(counts))
+	for reason := range counts {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		log.Printf("%d item(s) filtered: %s", counts[reason], reason)
+	}
+}
+
+func stringSet(values []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		set[value] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,333 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// --input value reading the card definition file from the standard input.
+const INPUT_STDIN string = "-"
+
+// The standard input can only be read once: it is buffered for the validation,
+// the backup manifest and the parsing.
+var stdinInput []byte
+var stdinRead bool
+
+// openInput opens the card definition given by --input: a JSON file, a .gz, .zst or .zip
+// compressed file, a directory of per-card JSON files or the standard input.
+func openInput(path string) (io.ReadCloser, error) {
+	if path == INPUT_STDIN {
+		if !stdinRead {
+			data, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				return nil, fmt.Errorf("Error while reading the standard input: %s", err)
+			}
+			stdinInput, stdinRead = data, true
+		}
+		return ioutil.NopCloser(bytes.NewReader(stdinInput)), nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return readCardDirectory(path)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz":
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("Error while decompressing %s: %s", path, err)
+		}
+		return &inputReader{Reader: reader, closers: []io.Closer{reader, file}}, nil
+	case ".zst":
+		return openZstd(path)
+	case ".zip":
+		return openZip(path)
+	default:
+		return os.Open(path)
+	}
+}
+
+// readInput returns the whole card definition given by --input, see openInput.
+func readInput(path string) ([]byte, error) {
+	reader, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// inputReader closes the readers stacked to decompress a file, the outermost first.
+type inputReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *inputReader) Close() error {
+	var err error
+	for _, closer := range r.closers {
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// openZstd decompresses a file with the zstd command line tool, which must be installed.
+func openZstd(path string) (io.ReadCloser, error) {
+	command, err := exec.LookPath("zstd")
+	if err != nil {
+		return nil, fmt.Errorf("Can't decompress %s: the zstd command line tool is required for .zst files and wasn't found (%s), install it or decompress the file first", path, err)
+	}
+	reader := &commandReader{cmd: exec.Command(command, "-dc", path)}
+	reader.cmd.Stderr = &reader.stderr
+	stdout, err := reader.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := reader.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("Error while decompressing %s: %s", path, err)
+	}
+	reader.stdout = stdout
+	return reader, nil
+}
+
+// commandReader reads the output of a command. The failure of the command
+// is returned by the read reaching the end of the output.
+type commandReader struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr bytes.Buffer
+	done   bool
+}
+
+func (r *commandReader) Read(p []byte) (int, error) {
+	n, err := r.stdout.Read(p)
+	if err == io.EOF && !r.done {
+		r.done = true
+		if waitErr := r.cmd.Wait(); waitErr != nil {
+			return n, fmt.Errorf("Error while running %s: %s\n%s", r.cmd.Path, waitErr, r.stderr.String())
+		}
+	}
+	return n, err
+}
+
+func (r *commandReader) Close() error {
+	if r.done {
+		return nil
+	}
+	r.done = true
+	r.cmd.Process.Kill()
+	r.cmd.Wait()
+	return nil
+}
+
+// openZip reads a zip bundle of JSON files, each holding a card or a card definition
+// (see cardBundle.add). A bundle holding a single card definition is read as it is.
+func openZip(path string) (io.ReadCloser, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error while opening %s: %s", path, err)
+	}
+	var files []*zip.File
+	for _, file := range archive.File {
+		if !file.FileInfo().IsDir() && isJSONFile(file.Name) {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 1 {
+		card, err := isCardFile(files[0])
+		if err != nil {
+			archive.Close()
+			return nil, fmt.Errorf("Error while reading %s: %s", files[0].Name, err)
+		}
+		if !card {
+			reader, err := files[0].Open()
+			if err != nil {
+				archive.Close()
+				return nil, err
+			}
+			return &inputReader{Reader: reader, closers: []io.Closer{reader, archive}}, nil
+		}
+	}
+	defer archive.Close()
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	bundle := newCardBundle()
+	for _, file := range files {
+		reader, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Error while reading %s: %s", file.Name, err)
+		}
+		if err := bundle.add(file.Name, data); err != nil {
+			return nil, err
+		}
+	}
+	return bundle.definition()
+}
+
+// isCardFile reads a JSON file of a zip bundle, value by value, to tell a card from a card
+// definition (see isCardValue).
+func isCardFile(file *zip.File) (bool, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+	decoder := json.NewDecoder(reader)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return false, fmt.Errorf("expected a JSON object")
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return false, err
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return false, err
+		}
+		if key, ok := token.(string); ok && isCardValue(key, value) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isCardValue returns true if a key and its value of a JSON file can only be found in a card:
+// a key identifying a card, or a value that isn't an object as the cards of a card definition.
+// A card with a misspelled field is still a card, the validation reports the field.
+func isCardValue(key string, value json.RawMessage) bool {
+	for _, name := range []string{"Name", "IngameId", "Variations"} {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	value = bytes.TrimLeft(value, " \t\r\n")
+	return len(value) == 0 || value[0] != '{'
+}
+
+// readCardDirectory merges the JSON files found in a directory and its subdirectories,
+// each holding a card or a card definition (see cardBundle.add).
+func readCardDirectory(path string) (io.ReadCloser, error) {
+	bundle := newCardBundle()
+	err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isJSONFile(file) {
+			return nil
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		return bundle.add(file, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bundle.definition()
+}
+
+func isJSONFile(name string) bool {
+	return strings.ToLower(filepath.Ext(name)) == ".json"
+}
+
+// cardBundle merges card files and card definition files into a card definition.
+// A card of a card file is keyed by its IngameId, or by its file name if it doesn't have one.
+type cardBundle struct {
+	cards map[string]json.RawMessage
+	// File of each card, to report the duplicates.
+	files      map[string]string
+	duplicates []string
+}
+
+func newCardBundle() *cardBundle {
+	return &cardBundle{
+		cards: make(map[string]json.RawMessage),
+		files: make(map[string]string),
+	}
+}
+
+// add merges a file: a card if one of its values can only be found in a card (see isCardValue),
+// otherwise a card definition.
+func (b *cardBundle) add(file string, data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("Error while parsing %s: %s", file, err)
+	}
+	keys := make([]string, 0, len(fields))
+	single := false
+	for key, value := range fields {
+		keys = append(keys, key)
+		single = single || isCardValue(key, value)
+	}
+	if !single {
+		sort.Strings(keys)
+		for _, key := range keys {
+			b.addCard(file, key, fields[key])
+		}
+		return nil
+	}
+	var card struct {
+		IngameId string
+	}
+	if err := json.Unmarshal(data, &card); err != nil {
+		return fmt.Errorf("Error while parsing %s: %s", file, err)
+	}
+	key := card.IngameId
+	if len(key) == 0 {
+		key = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	b.addCard(file, key, data)
+	return nil
+}
+
+func (b *cardBundle) addCard(file, key string, data []byte) {
+	if previous, ok := b.files[key]; ok {
+		b.duplicates = append(b.duplicates, fmt.Sprintf("card %s is defined by %s and %s", key, previous, file))
+		return
+	}
+	b.cards[key] = json.RawMessage(data)
+	b.files[key] = file
+}
+
+// definition returns the merged card definition, or an error listing every duplicate card.
+func (b *cardBundle) definition() (io.ReadCloser, error) {
+	if len(b.duplicates) > 0 {
+		return nil, fmt.Errorf("Duplicate cards: %s", strings.Join(b.duplicates, "; "))
+	}
+	if len(b.cards) == 0 {
+		return nil, errors.New("No card file found")
+	}
+	data, err := json.Marshal(b.cards)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
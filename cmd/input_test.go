@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestCardBundleAdd(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		data  string
+		cards []string
+	}{
+		{
+			name:  "card",
+			file:  "cards/geralt.json",
+			data:  `{"Name": {"en-US": "Geralt"}, "Strength": 13, "IngameId": "112101"}`,
+			cards: []string{"112101"},
+		},
+		{
+			name:  "card with a misspelled field",
+			file:  "cards/geralt.json",
+			data:  `{"Name": {"en-US": "Geralt"}, "Strenght": 13, "IngameId": "112101"}`,
+			cards: []string{"112101"},
+		},
+		{
+			name:  "card without identifying field is keyed by its file name",
+			file:  "cards/geralt.json",
+			data:  `{"Info": {"en-US": "Deploy: Deal 3 damage."}, "Strenght": 13}`,
+			cards: []string{"geralt"},
+		},
+		{
+			name:  "definition",
+			file:  "definition.json",
+			data:  `{"112101": {"Name": {"en-US": "Geralt"}}, "112102": {"Name": {"en-US": "Roach"}}}`,
+			cards: []string{"112101", "112102"},
+		},
+		{
+			name:  "empty definition",
+			file:  "definition.json",
+			data:  `{}`,
+			cards: []string{},
+		},
+	}
+	for _, test := range tests {
+		bundle := newCardBundle()
+		if err := bundle.add(test.file, []byte(test.data)); err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		cards := []string{}
+		for key := range bundle.cards {
+			cards = append(cards, key)
+		}
+		sort.Strings(cards)
+		if len(cards) != len(test.cards) {
+			t.Errorf("%s: got cards %v, expected %v", test.name, cards, test.cards)
+			continue
+		}
+		for i := range cards {
+			if cards[i] != test.cards[i] {
+				t.Errorf("%s: got cards %v, expected %v", test.name, cards, test.cards)
+				break
+			}
+		}
+	}
+}
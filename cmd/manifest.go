@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const MANIFEST_FILE string = "manifest.json"
+
+const (
+	BACKUP_STATUS_SUCCESS string = "success"
+	BACKUP_STATUS_FAILED  string = "failed"
+)
+
+// BackupManifest is written as manifest.json next to every backup to describe its content.
+type BackupManifest struct {
+	CreatedAt          time.Time      `json:"createdAt"`
+	ManipulatorVersion string         `json:"manipulatorVersion"`
+	Hosts              []string       `json:"hosts"`
+	Database           string         `json:"database,omitempty"`
+	Input              *ManifestInput `json:"input,omitempty"`
+	Engine             string         `json:"engine,omitempty"`
+	Format             string         `json:"format,omitempty"`
+	// Number of documents per collection, the key is <database>.<collection>
+	Collections map[string]int `json:"collections"`
+	Status      string         `json:"status"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// ManifestInput identifies the card definition file that triggered the backup.
+type ManifestInput struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+func writeManifest(folder string, manifest BackupManifest) error {
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(folder, MANIFEST_FILE), data, 0644)
+}
+
+// readManifest returns nil without error if the backup doesn't have a manifest.
+func readManifest(folder string) (*BackupManifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(folder, MANIFEST_FILE))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	manifest := &BackupManifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("Invalid manifest in %s: %s", folder, err)
+	}
+	return manifest, nil
+}
+
+// newManifestInput hashes the input file as is. The standard input and the
+// directories are hashed as the card definition merged by openInput.
+func newManifestInput(path string) (*ManifestInput, error) {
+	if info, err := os.Stat(path); path == INPUT_STDIN || err == nil && info.IsDir() {
+		data, err := readInput(path)
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.Sum256(data)
+		if path != INPUT_STDIN {
+			path, _ = filepath.Abs(path)
+		}
+		return &ManifestInput{Path: path, SHA256: hex.EncodeToString(hash[:])}, nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := fileSHA256(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ManifestInput{Path: abs, SHA256: hash}, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// countBackupDocuments counts the documents of every collection dumped by mongodump
+// (<database>/<collection>.bson.gz) in the backup folder.
+func countBackupDocuments(folder string) (map[string]int, error) {
+	counts := make(map[string]int)
+	files, err := filepath.Glob(filepath.Join(folder, "*", "*.bson.gz"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		database := filepath.Base(filepath.Dir(path))
+		collection := strings.TrimSuffix(filepath.Base(path), ".bson.gz")
+		count, err := countGzipBSONDocuments(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error while reading %s: %s", path, err)
+		}
+		counts[database+"."+collection] = count
+	}
+	return counts, nil
+}
+
+func countGzipBSONDocuments(path string) (int, error) {
+	return readGzipBSONDocuments(path, nil)
+}
+
+// readGzipBSONDocuments calls fn, if not nil, for each document of a gzip compressed
+// stream of BSON documents and returns the number of documents read.
+func readGzipBSONDocuments(path string, fn func(document []byte) error) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	return readBSONDocuments(reader, fn)
+}
+
+// readBSONDocuments reads a stream of BSON documents.
+// Each document starts with its size as a little endian int32.
+func readBSONDocuments(reader io.Reader, fn func(document []byte) error) (int, error) {
+	count := 0
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if err == io.EOF {
+				return count, nil
+			}
+			return count, err
+		}
+		size := int32(binary.LittleEndian.Uint32(header))
+		if size < 5 {
+			return count, errors.New("invalid BSON document size")
+		}
+		if fn == nil {
+			if _, err := io.CopyN(ioutil.Discard, reader, int64(size-4)); err != nil {
+				return count, err
+			}
+		} else {
+			document := make([]byte, size)
+			copy(document, header)
+			if _, err := io.ReadFull(reader, document[4:]); err != nil {
+				return count, err
+			}
+			if err := fn(document); err != nil {
+				return count, fmt.Errorf("document %d: %s", count, err)
+			}
+		}
+		count++
+	}
+}
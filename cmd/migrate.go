@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	db "github.com/GwentAPI/manipulator/database"
+	"github.com/spf13/cobra"
+	"log"
+	"time"
+)
+
+var migrateFrom string
+var migrateTo string
+
+// migrateUUIDCmd represents the migrate-uuid command
+var migrateUUIDCmd = &cobra.Command{
+	Use:   "migrate-uuid",
+	Short: "Re-key the cards and variations with another UUID strategy.",
+	Long: `Re-key the cards and variations with another UUID strategy.
+
+The UUIDs of the cards and variations of the input file are computed with
+both strategies, and the documents found with the old UUID get the new one.
+They keep their _id, so the references of the other collections stay valid.
+Every change is recorded in the card_aliases collection so that the old
+URLs can be redirected. A backup is created first.
+
+Once migrated, set uuid.strategy in the configuration file (or use
+--uuid-strategy) so that the following updates use the new strategy.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		start := time.Now()
+		from, to := repo, repo
+		from.UUIDStrategy, to.UUIDStrategy = migrateFrom, migrateTo
+		if from.UUIDStrategy == to.UUIDStrategy {
+			return fmt.Errorf("--from and --to are both %s", from.UUIDStrategy)
+		}
+		if err := resolveMongoSettings(cmd); err != nil {
+			return err
+		}
+		result, err := parseData(filePath, nil)
+		if err != nil {
+			return withExitCode(EXIT_INPUT, fmt.Errorf("Error while parsing the data: %s", err))
+		}
+		if err := to.CheckUUIDStrategy(result.Cards); err != nil {
+			return withExitCode(EXIT_INPUT, err)
+		}
+		if _, err := createBackup(filePath); err != nil {
+			return withExitCode(EXIT_BACKUP, err)
+		}
+		if err := migrateUUIDs(from, to, result); err != nil {
+			return err
+		}
+		log.Printf("Finished in %s", time.Since(start))
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(migrateUUIDCmd)
+
+	migrateUUIDCmd.Flags().AddFlagSet(mongoFlags)
+	migrateUUIDCmd.Flags().StringVar(&migrateFrom, "from", db.UUID_STRATEGY_NAME, "UUID strategy of the documents in the database.")
+	migrateUUIDCmd.Flags().StringVar(&migrateTo, "to", db.UUID_STRATEGY_INGAMEID, "New UUID strategy.")
+}
+
+func migrateUUIDs(from, to db.ReposClient, container *DataContainer) error {
+	log.Println("Attempting to establish mongoDB session...")
+	session, err := repo.CreateSession(mongoDBAuthentication)
+	if err != nil {
+		return withExitCode(EXIT_CONNECTION, fmt.Errorf("Failed to establish mongoDB connection: %s", err))
+	}
+	defer session.Close()
+	database := session.DB("")
+
+	cardChanges, err := from.CardUUIDChanges(to, container.Cards)
+	if err != nil {
+		return err
+	}
+	variationChanges, err := from.VariationUUIDChanges(to, container.Cards)
+	if err != nil {
+		return err
+	}
+	for _, migration := range []struct {
+		collection string
+		changes    []db.UUIDChange
+	}{
+		{"cards", cardChanges},
+		{"variations", variationChanges},
+	} {
+		count, err := repo.MigrateUUIDs(database, migration.collection, migration.changes)
+		if err != nil {
+			return err
+		}
+		if err := repo.InsertAliases(database, migration.collection, migration.changes); err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d of %d document(s) re-keyed\n", migration.collection, count, len(migration.changes))
+	}
+	return nil
+}
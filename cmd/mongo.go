@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	db "github.com/GwentAPI/manipulator/database"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"log"
+	"strings"
+	"time"
+)
+
+var mongoDBAuthentication = db.MongoConnectionSettings{
+	Timeout: 15 * time.Second,
+}
+
+var mongoURI string
+var passwordFile string
+
+// mongoFlags holds the connection flags shared by every command that connects to mongoDB.
+var mongoFlags = newMongoFlagSet()
+
+// Flags that can't be combined with --uri.
+var mongoURIConflicts = []string{"host", "u", "p", "authenticationDatabase", "ssl", "db"}
+
+func newMongoFlagSet() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("mongo", pflag.ContinueOnError)
+	flags.StringVar(&mongoURI, "uri", "", "mongoDB connection string: mongodb://[username[:password]@]host1[:port1][,host2[:port2],...][/database][?options]")
+	flags.StringVar(&mongoDBAuthentication.Username, "u", "", "Username used for mongoDB authentication.")
+	flags.StringVar(&mongoDBAuthentication.AuthenticationDatabase, "authenticationDatabase", "", "Authentication database for mongoDB.")
+	flags.StringVar(&mongoDBAuthentication.Password, "p", "", "User password for mongoDB authentication.")
+	flags.StringVar(&passwordFile, "passwordFile", "", "File containing the password for mongoDB authentication.")
+	flags.StringSliceVar(&mongoDBAuthentication.Host, "host", []string{"localhost"}, "List of mongoDB server addresses on standard mongoDB port.")
+	flags.BoolVar(&mongoDBAuthentication.UseSSL, "ssl", false, "Set to true if you require SSL to connect to the database")
+	flags.StringVar(&mongoDBAuthentication.Db, "db", "", "Use default mongoDb database if not specified (default test).")
+	flags.StringVar(&mongoDBAuthentication.TLSCAFile, "tlsCAFile", "", "PEM file of the certificate authorities used to verify the server (default the CA of the system).")
+	flags.StringVar(&mongoDBAuthentication.TLSCertificateKeyFile, "tlsCertificateKeyFile", "", "PEM file containing the client certificate and its private key.")
+	flags.StringVar(&mongoDBAuthentication.TLSServerName, "tlsServerName", "", "Server name expected in the certificate of the server, also sent with SNI.")
+	flags.BoolVar(&mongoDBAuthentication.TLSInsecure, "tlsInsecure", false, "INSECURE: don't verify the certificate of the server. For testing only.")
+	return flags
+}
+
+// resolveMongoSettings completes mongoDBAuthentication from --uri and the password sources.
+// It must be called by the commands using mongoFlags before connecting to mongoDB.
+func resolveMongoSettings(cmd *cobra.Command) error {
+	if len(mongoURI) > 0 {
+		var conflicts []string
+		for _, name := range mongoURIConflicts {
+			if cmd.Flags().Changed(name) {
+				conflicts = append(conflicts, "--"+name)
+			}
+		}
+		if len(conflicts) > 0 {
+			return fmt.Errorf("--uri can't be combined with %s", strings.Join(conflicts, ", "))
+		}
+		settings, err := db.ParseMongoURI(mongoURI)
+		if err != nil {
+			return fmt.Errorf("Invalid mongoDB URI: %s", err)
+		}
+		if settings.Timeout == 0 {
+			settings.Timeout = mongoDBAuthentication.Timeout
+		}
+		// The TLS flags can be combined with --uri and take precedence.
+		if cmd.Flags().Changed("tlsCAFile") {
+			settings.TLSCAFile = mongoDBAuthentication.TLSCAFile
+		}
+		if cmd.Flags().Changed("tlsCertificateKeyFile") {
+			settings.TLSCertificateKeyFile = mongoDBAuthentication.TLSCertificateKeyFile
+		}
+		if cmd.Flags().Changed("tlsServerName") {
+			settings.TLSServerName = mongoDBAuthentication.TLSServerName
+		}
+		if cmd.Flags().Changed("tlsInsecure") {
+			settings.TLSInsecure = mongoDBAuthentication.TLSInsecure
+		}
+		mongoDBAuthentication = settings
+	}
+	if mongoDBAuthentication.UsesTLSOptions() {
+		mongoDBAuthentication.UseSSL = true
+	}
+	if mongoDBAuthentication.TLSInsecure {
+		log.Println("WARNING: the certificate of the server won't be verified (--tlsInsecure). The connection is vulnerable to man-in-the-middle attacks.")
+	}
+	if len(mongoDBAuthentication.Host) == 0 {
+		mongoDBAuthentication.Host = []string{"localhost"}
+	}
+	return resolvePassword()
+}
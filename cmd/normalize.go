@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/GwentAPI/manipulator/models"
+	"github.com/spf13/viper"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Types of normalization rules.
+const (
+	RULE_RENAME       string = "rename"
+	RULE_TRIM         string = "trim"
+	RULE_SET_NAME     string = "set-name"
+	RULE_STRIP_MARKUP string = "strip-markup"
+)
+
+// normalizationRule fixes a quirk of the definition file after it is decoded.
+type normalizationRule struct {
+	Type string
+	// rename: faction, type, rarity, availability, category, position or loyalty.
+	Field string
+	From  string
+	To    string
+	// set-name: the card to rename and the locale of the name (en-US by default).
+	IngameId string
+	Locale   string
+}
+
+// normalizationResult is the number of values changed by a rule, and the values changed.
+type normalizationResult struct {
+	Rule    string           `json:"rule"`
+	Changes int              `json:"changes"`
+	Items   []normalizedItem `json:"items,omitempty"`
+}
+
+// normalizedItem is a value changed by a rule.
+type normalizedItem struct {
+	Card     string `json:"card"`
+	IngameId string `json:"ingameId,omitempty"`
+	// Field of the value, e.g. faction, name fr-FR or rarity of variation 11.
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// The data file doesn't have Scoia'tael spelled correctly, so we rename it.
+var defaultNormalizationRules = []normalizationRule{
+	{Type: RULE_RENAME, Field: "faction", From: "Scoiatael", To: "Scoia'tael"},
+}
+
+var markupPattern = regexp.MustCompile(`<[^<>]*>`)
+
+func (r normalizationRule) String() string {
+	switch r.Type {
+	case RULE_RENAME:
+		return fmt.Sprintf("rename %s %q to %q", r.Field, r.From, r.To)
+	case RULE_SET_NAME:
+		return fmt.Sprintf("set the %s name of %s to %q", r.locale(), r.IngameId, r.To)
+	default:
+		return r.Type
+	}
+}
+
+func (r normalizationRule) locale() string {
+	if len(r.Locale) == 0 {
+		return "en-US"
+	}
+	return r.Locale
+}
+
+// currentNormalizationRules returns the default rules followed by the rules of the file given
+// by --rules, or of normalize.rules in the configuration file. The default rules are always
+// applied unless normalize.defaults is false.
+func currentNormalizationRules() ([]normalizationRule, error) {
+	var rules []normalizationRule
+	if !viper.IsSet("normalize.defaults") || viper.GetBool("normalize.defaults") {
+		rules = append(rules, defaultNormalizationRules...)
+	}
+	config, key := viper.GetViper(), "normalize.rules"
+	if path := viper.GetString("normalize.file"); len(path) > 0 {
+		config, key = viper.New(), "rules"
+		config.SetConfigFile(path)
+		if err := config.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("Error while reading the rules: %s", err)
+		}
+		if !config.IsSet(key) {
+			return nil, fmt.Errorf("No rules in %s", path)
+		}
+	}
+	if !config.IsSet(key) {
+		return rules, nil
+	}
+	configured, err := unmarshalRules(config, key)
+	if err != nil {
+		return nil, err
+	}
+	return append(rules, configured...), nil
+}
+
+func unmarshalRules(config *viper.Viper, key string) ([]normalizationRule, error) {
+	var rules []normalizationRule
+	if err := config.UnmarshalKey(key, &rules); err != nil {
+		return nil, fmt.Errorf("Invalid rules: %s", err)
+	}
+	for _, rule := range rules {
+		if err := rule.check(); err != nil {
+			return nil, fmt.Errorf("Invalid rule %s: %s", rule, err)
+		}
+	}
+	return rules, nil
+}
+
+func (r normalizationRule) check() error {
+	switch r.Type {
+	case RULE_RENAME:
+		switch r.Field {
+		case "faction", "type", "rarity", "availability", "category", "position", "loyalty":
+		default:
+			return fmt.Errorf("unknown field %q", r.Field)
+		}
+	case RULE_SET_NAME:
+		if len(r.IngameId) == 0 {
+			return fmt.Errorf("no ingameId")
+		}
+	case RULE_TRIM, RULE_STRIP_MARKUP:
+	default:
+		return fmt.Errorf("unknown type %q", r.Type)
+	}
+	return nil
+}
+
+// normalizer applies the rules in order, card by card, and records the values changed by each rule.
+type normalizer struct {
+	rules []normalizationRule
+	items [][]normalizedItem
+}
+
+func newNormalizer(rules []normalizationRule) *normalizer {
+	return &normalizer{rules: rules, items: make([][]normalizedItem, len(rules))}
+}
+
+func (n *normalizer) normalize(card *models.GwentCard) {
+	for i, rule := range n.rules {
+		n.items[i] = append(n.items[i], rule.apply(card)...)
+	}
+}
+
+// results logs and returns the changes of each rule.
+func (n *normalizer) results() []normalizationResult {
+	var results []normalizationResult
+	for i, rule := range n.rules {
+		items := n.items[i]
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].Card < items[j].Card
+		})
+		log.Printf("Normalization rule %s: %d change(s)", rule, len(items))
+		results = append(results, normalizationResult{Rule: rule.String(), Changes: len(items), Items: items})
+	}
+	return results
+}
+
+// apply changes the card and returns the values changed.
+func (r normalizationRule) apply(card *models.GwentCard) []normalizedItem {
+	var items []normalizedItem
+	cardName := card.Name["en-US"]
+	replace := func(field string, value *string, new string) {
+		if *value != new {
+			items = append(items, normalizedItem{Card: cardName, IngameId: card.IngameId, Field: field, Old: *value, New: new})
+			*value = new
+		}
+	}
+	rename := func(field string) func(*string) {
+		return func(value *string) {
+			if *value == r.From {
+				replace(field, value, r.To)
+			}
+		}
+	}
+	texts := func(field string, texts map[string]string, fix func(string) string) {
+		for _, locale := range sortedLocales(texts) {
+			text := texts[locale]
+			replace(field+" "+locale, &text, fix(text))
+			texts[locale] = text
+		}
+	}
+
+	switch r.Type {
+	case RULE_RENAME:
+		switch r.Field {
+		case "faction":
+			rename(r.Field)(&card.Faction)
+		case "type":
+			rename(r.Field)(&card.Group)
+		case "category":
+			card.Categories = renameAll(card.Categories, rename(r.Field))
+		case "position":
+			card.Positions = renameAll(card.Positions, rename(r.Field))
+		case "loyalty":
+			card.Loyalties = renameAll(card.Loyalties, rename(r.Field))
+		case "rarity", "availability":
+			for _, key := range card.VariationKeys() {
+				variation := card.Variations[key]
+				field := fmt.Sprintf("%s of variation %s", r.Field, key)
+				if r.Field == "rarity" {
+					rename(field)(&variation.Rarity)
+				} else {
+					rename(field)(&variation.Availability)
+				}
+				card.Variations[key] = variation
+			}
+		}
+	case RULE_TRIM:
+		texts("name", card.Name, strings.TrimSpace)
+		texts("info", card.Info, strings.TrimSpace)
+		texts("flavor", card.Flavor, strings.TrimSpace)
+	case RULE_STRIP_MARKUP:
+		texts("info", card.Info, func(text string) string {
+			return markupPattern.ReplaceAllString(text, "")
+		})
+	case RULE_SET_NAME:
+		if card.IngameId == r.IngameId {
+			if card.Name == nil {
+				card.Name = make(map[string]string)
+			}
+			name := card.Name[r.locale()]
+			replace("name "+r.locale(), &name, r.To)
+			card.Name[r.locale()] = name
+		}
+	}
+	return items
+}
+
+// renameAll returns a renamed copy of values. A nil slice stays nil, so that an absent
+// field isn't stored as an empty list.
+func renameAll(values []string, rename func(*string)) []string {
+	if values == nil {
+		return nil
+	}
+	renamed := make([]string, len(values))
+	for i, value := range values {
+		rename(&value)
+		renamed[i] = value
+	}
+	return renamed
+}
+
+func sortedLocales(texts map[string]string) []string {
+	locales := make([]string, 0, len(texts))
+	for locale := range texts {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
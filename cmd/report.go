@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/GwentAPI/manipulator/models"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"log"
+	"time"
+)
+
+const (
+	REPORT_STATUS_SUCCESS string = "success"
+	REPORT_STATUS_FAILED  string = "failed"
+)
+
+// runReport is the machine readable report of a command, written to the file given by --report.
+type runReport struct {
+	Command   string    `json:"command"`
+	StartedAt time.Time `json:"startedAt"`
+	Seconds   float64   `json:"seconds"`
+	Status    string    `json:"status"`
+	ExitCode  int       `json:"exitCode"`
+	Error     string    `json:"error,omitempty"`
+	// Card definition given by --input.
+	Input       *ManifestInput          `json:"input,omitempty"`
+	Counts      *reportCounts           `json:"counts,omitempty"`
+	Filtered    []filteredItem          `json:"filtered,omitempty"`
+	Normalized  []normalizationResult   `json:"normalized,omitempty"`
+	Collections map[string]*writeReport `json:"collections,omitempty"`
+	Artwork     *artworkReport          `json:"artwork,omitempty"`
+	// Folder of the backup created by the command.
+	Backup string        `json:"backup,omitempty"`
+	Phases []phaseReport `json:"phases,omitempty"`
+}
+
+// reportCounts are the numbers of released cards, variations and generic values of the input.
+type reportCounts struct {
+	Cards      int `json:"cards"`
+	Variations int `json:"variations"`
+	Groups     int `json:"groups"`
+	Rarities   int `json:"rarities"`
+	Factions   int `json:"factions"`
+	Categories int `json:"categories"`
+}
+
+// writeReport counts the documents written to a collection by update.
+type writeReport struct {
+	Matched  int `json:"matched"`
+	Modified int `json:"modified"`
+	Deleted  int `json:"deleted"`
+}
+
+type artworkReport struct {
+	Downloaded int64 `json:"downloaded"`
+	// Variations without art in the card definition.
+	Skipped int64 `json:"skipped"`
+	Failed  int64 `json:"failed"`
+}
+
+type phaseReport struct {
+	Name    string  `json:"name"`
+	Seconds float64 `json:"seconds"`
+}
+
+// currentReport is filled by the running command. It is only written with --report.
+var currentReport = &runReport{StartedAt: time.Now()}
+
+var reportPath string
+
+// recordInput hashes the input file for the report, only if a report is requested.
+func (r *runReport) recordInput(path string) error {
+	if len(reportPath) == 0 {
+		return nil
+	}
+	input, err := newManifestInput(path)
+	if err != nil {
+		return err
+	}
+	r.Input = input
+	return nil
+}
+
+// recordData counts the cards and the generic values of the container.
+// The number of cards and variations is given when the container doesn't hold the cards.
+func (r *runReport) recordData(container *DataContainer, cards, variations int) {
+	r.Counts = &reportCounts{
+		Cards:      cards,
+		Variations: variations,
+		Groups:     len(container.Groups),
+		Rarities:   len(container.Rarities),
+		Factions:   len(container.Factions),
+		Categories: len(container.Categories),
+	}
+	r.Filtered = container.Filtered
+	r.Normalized = container.Normalized
+}
+
+// collection returns the write counts of a collection, named without the staging suffix.
+func (r *runReport) collection(name string) *writeReport {
+	if r.Collections == nil {
+		r.Collections = make(map[string]*writeReport)
+	}
+	if _, ok := r.Collections[name]; !ok {
+		r.Collections[name] = &writeReport{}
+	}
+	return r.Collections[name]
+}
+
+// write saves the report with the outcome of the command.
+func (r *runReport) write(cmd *cobra.Command, err error) {
+	if cmd != nil {
+		r.Command = cmd.CommandPath()
+	}
+	r.Seconds = time.Since(r.StartedAt).Seconds()
+	r.Status = REPORT_STATUS_SUCCESS
+	if err != nil {
+		r.Status = REPORT_STATUS_FAILED
+		r.ExitCode = exitCode(err)
+		r.Error = err.Error()
+	}
+	data, marshalErr := json.MarshalIndent(r, "", "  ")
+	if marshalErr == nil {
+		marshalErr = ioutil.WriteFile(reportPath, data, 0644)
+	}
+	if marshalErr != nil {
+		log.Printf("Error while writing the report: %s", marshalErr)
+	}
+}
+
+func countVariations(cards map[string]models.GwentCard) int {
+	count := 0
+	for _, card := range cards {
+		count += len(card.Variations)
+	}
+	return count
+}
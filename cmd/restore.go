@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"log"
+	"time"
+)
+
+var restoreLatest bool
+var restoreForce bool
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore [backup]",
+	Short: "Restore the mongoDB databases from a backup.",
+	Long: `Restore the mongoDB databases from a backup.
+
+Without argument, the available backups are listed. A backup is selected
+by its timestamp (the name of its folder) or with the --latest flag.
+The failed backups are ignored by --latest, and are only restored by name
+with --force.
+
+mongorestore will be use to restore the gziped backup. The collections
+being restored are dropped first. A backup of the current state is created
+before anything is overwritten.`,
+	Args:              cobra.MaximumNArgs(1),
+	PersistentPreRunE: noInputRequired,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		start := time.Now()
+		if len(args) == 0 && !restoreLatest {
+			return printBackups()
+		}
+		snapshot, err := selectBackup(args)
+		if err != nil {
+			return err
+		}
+		if err := resolveMongoSettings(cmd); err != nil {
+			return err
+		}
+		// Nothing is backed up if the backup can't be restored.
+		if err := checkRestorable(snapshot, restoreForce); err != nil {
+			return err
+		}
+		log.Println("Creating a safety backup of the current state...")
+		if _, err := createBackup(""); err != nil {
+			return withExitCode(EXIT_BACKUP, err)
+		}
+		log.Println("Restoring backup ", snapshot.Name, "...")
+		if err := restoreBackup(snapshot); err != nil {
+			return err
+		}
+		elapsed := time.Since(start)
+		log.Printf("Finished in %s", elapsed)
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(restoreCmd)
+
+	restoreCmd.Flags().BoolVar(&restoreLatest, "latest", false, "Restore the most recent backup.")
+	restoreCmd.Flags().BoolVar(&restoreForce, "force", false, "Restore a backup even if its manifest reports a failure.")
+	restoreCmd.Flags().AddFlagSet(mongoFlags)
+}
+
+func selectBackup(args []string) (backupSnapshot, error) {
+	if len(args) > 0 {
+		if restoreLatest {
+			return backupSnapshot{}, errors.New("A backup can't be specified along with --latest")
+		}
+		return findBackup(args[0])
+	}
+	snapshots, err := listBackups(false)
+	if err != nil {
+		return backupSnapshot{}, err
+	}
+	if len(snapshots) == 0 {
+		return backupSnapshot{}, fmt.Errorf("No backup found in %s", backupFolder())
+	}
+	return snapshots[len(snapshots)-1], nil
+}
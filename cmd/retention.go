@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"github.com/spf13/viper"
+	"log"
+	"os"
+	"time"
+)
+
+// retentionPolicy decides which backups are kept when pruning the backup folder.
+// A zero value disables the corresponding rule.
+type retentionPolicy struct {
+	KeepLast   int
+	KeepDaily  int
+	KeepWeekly int
+}
+
+func currentRetentionPolicy() retentionPolicy {
+	return retentionPolicy{
+		KeepLast:   viper.GetInt("backup.retention.keepLast"),
+		KeepDaily:  viper.GetInt("backup.retention.keepDaily"),
+		KeepWeekly: viper.GetInt("backup.retention.keepWeekly"),
+	}
+}
+
+func (p retentionPolicy) isEmpty() bool {
+	return p.KeepLast <= 0 && p.KeepDaily <= 0 && p.KeepWeekly <= 0
+}
+
+// apply splits the snapshots (oldest first) between the ones to keep and the ones to remove.
+// The daily and weekly rules count calendar days and ISO weeks back from now, the current
+// one included: KeepWeekly 2 keeps the last backup of this week and of the previous one.
+// The most recent successful backup is always kept. The failed backups are always removed:
+// they don't take the place of a successful backup in any rule.
+func (p retentionPolicy) apply(snapshots []backupSnapshot, now time.Time) (keep, remove []backupSnapshot) {
+	days := make(map[int]struct{})
+	weeks := make(map[int]struct{})
+
+	// Newest first, so that the most recent backup of a day or a week is the one kept.
+	successful := 0
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		snapshot := snapshots[i]
+		if snapshot.Failed {
+			remove = append(remove, snapshot)
+			continue
+		}
+		successful++
+		kept := successful == 1 || successful <= p.KeepLast
+
+		day := daysBetween(snapshot.Time, now)
+		if _, ok := days[day]; !ok && p.KeepDaily > 0 && day < p.KeepDaily {
+			days[day] = struct{}{}
+			kept = true
+		}
+		week := daysBetween(startOfISOWeek(snapshot.Time), startOfISOWeek(now)) / 7
+		if _, ok := weeks[week]; !ok && p.KeepWeekly > 0 && week < p.KeepWeekly {
+			weeks[week] = struct{}{}
+			kept = true
+		}
+
+		if kept {
+			keep = append(keep, snapshot)
+		} else {
+			remove = append(remove, snapshot)
+		}
+	}
+	return keep, remove
+}
+
+// daysBetween returns the number of calendar days from the day of t to the day of now.
+// The dates are compared in UTC so that a change of daylight saving time doesn't count.
+func daysBetween(t, now time.Time) int {
+	from := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours() / 24)
+}
+
+// startOfISOWeek returns the Monday of the ISO week of t.
+func startOfISOWeek(t time.Time) time.Time {
+	return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+}
+
+// pruneBackups deletes the backups that aren't kept by the configured retention policy.
+// Nothing is deleted if no retention policy is configured.
+func pruneBackups(dryRun bool) error {
+	policy := currentRetentionPolicy()
+	if policy.isEmpty() {
+		return nil
+	}
+	snapshots, err := listBackups(true)
+	if err != nil {
+		return err
+	}
+	_, remove := policy.apply(snapshots, time.Now())
+	if len(remove) == 0 {
+		log.Println("No backup to prune.")
+		return nil
+	}
+	for _, snapshot := range remove {
+		if dryRun {
+			log.Println("Would remove backup: ", snapshot.Name)
+			continue
+		}
+		if err := os.RemoveAll(snapshot.Path); err != nil {
+			return err
+		}
+		log.Println("Removed backup: ", snapshot.Name)
+	}
+	return nil
+}
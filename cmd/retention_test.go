@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Wednesday of ISO week 2017-46.
+var retentionNow = time.Date(2017, 11, 15, 12, 0, 0, 0, time.UTC)
+
+func snapshotsAt(times ...string) []backupSnapshot {
+	var snapshots []backupSnapshot
+	for _, value := range times {
+		t, err := time.Parse("2006-01-02 15:04", value)
+		if err != nil {
+			panic(err)
+		}
+		snapshots = append(snapshots, backupSnapshot{Name: value, Time: t})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+	return snapshots
+}
+
+func snapshotNames(snapshots []backupSnapshot) []string {
+	names := []string{}
+	for _, snapshot := range snapshots {
+		names = append(names, snapshot.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRetentionPolicyApply(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    retentionPolicy
+		snapshots []backupSnapshot
+		keep      []string
+	}{
+		{
+			name:      "the most recent backup is always kept",
+			policy:    retentionPolicy{},
+			snapshots: snapshotsAt("2017-11-13 10:00", "2017-11-14 10:00", "2017-11-15 10:00"),
+			keep:      []string{"2017-11-15 10:00"},
+		},
+		{
+			name:      "last",
+			policy:    retentionPolicy{KeepLast: 2},
+			snapshots: snapshotsAt("2017-11-13 10:00", "2017-11-14 10:00", "2017-11-15 09:00", "2017-11-15 10:00"),
+			keep:      []string{"2017-11-15 09:00", "2017-11-15 10:00"},
+		},
+		{
+			name:   "daily keeps the last backup of each day",
+			policy: retentionPolicy{KeepDaily: 2},
+			snapshots: snapshotsAt(
+				"2017-11-13 23:00",
+				"2017-11-14 08:00", "2017-11-14 20:00",
+				"2017-11-15 01:00", "2017-11-15 10:00",
+			),
+			keep: []string{"2017-11-14 20:00", "2017-11-15 10:00"},
+		},
+		{
+			name:      "daily counts calendar days, not 24 hour windows",
+			policy:    retentionPolicy{KeepDaily: 1},
+			snapshots: snapshotsAt("2017-11-14 23:59", "2017-11-15 00:01"),
+			keep:      []string{"2017-11-15 00:01"},
+		},
+		{
+			name:   "weekly keeps the last backup of each ISO week",
+			policy: retentionPolicy{KeepWeekly: 2},
+			snapshots: snapshotsAt(
+				// ISO week 2017-44.
+				"2017-11-02 10:00",
+				// ISO week 2017-45, Monday to Sunday.
+				"2017-11-06 10:00", "2017-11-08 10:00", "2017-11-12 10:00",
+				// ISO week 2017-46.
+				"2017-11-13 10:00", "2017-11-15 10:00",
+			),
+			keep: []string{"2017-11-12 10:00", "2017-11-15 10:00"},
+		},
+		{
+			name:   "weekly counts ISO weeks, not 7 day windows",
+			policy: retentionPolicy{KeepWeekly: 1},
+			// The Sunday is within 7 days but in the previous ISO week.
+			snapshots: snapshotsAt("2017-11-12 10:00", "2017-11-13 10:00"),
+			keep:      []string{"2017-11-13 10:00"},
+		},
+		{
+			name:   "a backup kept by any rule is kept",
+			policy: retentionPolicy{KeepLast: 1, KeepDaily: 2, KeepWeekly: 3},
+			snapshots: snapshotsAt(
+				"2017-10-25 10:00",
+				"2017-11-01 10:00", "2017-11-03 10:00",
+				"2017-11-08 10:00",
+				"2017-11-14 09:00", "2017-11-14 10:00",
+				"2017-11-15 09:00", "2017-11-15 10:00",
+			),
+			keep: []string{"2017-11-03 10:00", "2017-11-08 10:00", "2017-11-14 10:00", "2017-11-15 10:00"},
+		},
+	}
+	// The failed backups don't count and are removed.
+	failed := snapshotsAt("2017-11-13 10:00", "2017-11-14 10:00", "2017-11-15 09:00", "2017-11-15 10:00")
+	failed[1].Failed = true
+	failed[3].Failed = true
+	tests = append(tests, []struct {
+		name      string
+		policy    retentionPolicy
+		snapshots []backupSnapshot
+		keep      []string
+	}{
+		{
+			name:      "the most recent successful backup is kept",
+			policy:    retentionPolicy{KeepLast: 1},
+			snapshots: failed,
+			keep:      []string{"2017-11-15 09:00"},
+		},
+		{
+			name:      "failed backups don't take the slot of a successful backup",
+			policy:    retentionPolicy{KeepLast: 2, KeepDaily: 3},
+			snapshots: failed,
+			keep:      []string{"2017-11-13 10:00", "2017-11-15 09:00"},
+		},
+	}...)
+	for _, test := range tests {
+		keep, remove := test.policy.apply(test.snapshots, retentionNow)
+		if names := snapshotNames(keep); !reflect.DeepEqual(names, test.keep) {
+			t.Errorf("%s: kept %v, expected %v", test.name, names, test.keep)
+		}
+		if len(keep)+len(remove) != len(test.snapshots) {
+			t.Errorf("%s: %d kept and %d removed out of %d backups", test.name, len(keep), len(remove), len(test.snapshots))
+		}
+	}
+}
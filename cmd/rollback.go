@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+	db "github.com/GwentAPI/manipulator/database"
+	"github.com/spf13/cobra"
+	"log"
+	"time"
+)
+
+// rollbackCmd represents the rollback command
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Swap the live collections with the previous generation.",
+	Long: `Swap the live collections with the previous generation kept by update --atomic.
+
+The live collections become the previous generation, so running rollback
+again cancels the rollback.`,
+	PersistentPreRunE: noInputRequired,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		start := time.Now()
+		if err := resolveMongoSettings(cmd); err != nil {
+			return err
+		}
+		log.Println("Attempting to establish mongoDB session...")
+		session, err := repo.CreateSession(mongoDBAuthentication)
+		if err != nil {
+			return withExitCode(EXIT_CONNECTION, fmt.Errorf("Failed to establish mongoDB connection: %s", err))
+		}
+		defer session.Close()
+		if err := repo.RollbackStaging(session.DB(""), db.GwentCollections); err != nil {
+			return err
+		}
+		log.Printf("Finished in %s", time.Since(start))
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(rollbackCmd)
+
+	rollbackCmd.Flags().AddFlagSet(mongoFlags)
+}
@@ -3,7 +3,10 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	db "github.com/GwentAPI/manipulator/database"
 	"github.com/GwentAPI/manipulator/models"
+	"github.com/GwentAPI/manipulator/schema"
+	"github.com/GwentAPI/manipulator/validation"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -17,6 +20,30 @@ type DataContainer struct {
 	Rarities   map[string]struct{}
 	Factions   map[string]struct{}
 	Categories map[string]struct{}
+	// Cards and variations removed by the release filter.
+	Filtered []filteredItem
+	// Values changed by each normalization rule.
+	Normalized []normalizationResult
+	// Problems found in the released cards, when they are validated.
+	Findings []validation.Finding
+}
+
+// Version of manipulator, overridden at build time.
+var Version = "dev"
+
+type genericCollection struct {
+	Name   string
+	Values map[string]struct{}
+}
+
+// GenericCollections returns the generic collections in the order they are updated.
+func (c *DataContainer) GenericCollections() []genericCollection {
+	return []genericCollection{
+		{"groups", c.Groups},
+		{"rarities", c.Rarities},
+		{"factions", c.Factions},
+		{"categories", c.Categories},
+	}
 }
 
 var wg sync.WaitGroup
@@ -34,21 +61,36 @@ var RootCmd = &cobra.Command{
 This application is a tool to quickly perform maintenance operation on GwentAPI database and application.`,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		if len(filePath) == 0 {
-			return errors.New("Input file not provided")
+			return withExitCode(EXIT_INPUT, errors.New("Input file not provided"))
+		}
+		if _, err := os.Stat(filePath); err != nil && filePath != INPUT_STDIN {
+			return withExitCode(EXIT_INPUT, fmt.Errorf("Invalid file path: %s", filePath))
 		}
-		if _, err := os.Stat(filePath); err != nil {
-			return fmt.Errorf("Invalid file path: %s", filePath)
+		if err := currentReport.recordInput(filePath); err != nil {
+			return withExitCode(EXIT_INPUT, fmt.Errorf("Error while hashing the input file: %s", err))
 		}
 		return nil
 	},
 }
 
+// noInputRequired replaces the PersistentPreRunE of RootCmd for the commands
+// that don't read a card definition file.
+func noInputRequired(cmd *cobra.Command, args []string) error {
+	return nil
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// The exit code depends on the error: see EXIT_ERROR and the following constants.
+// With --report, the report of the command is written whether it failed or not.
 func Execute() {
-	if err := RootCmd.Execute(); err != nil {
+	cmd, err := RootCmd.ExecuteC()
+	if len(reportPath) > 0 {
+		currentReport.write(cmd, err)
+	}
+	if err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -59,7 +101,37 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.test.yaml)")
-	RootCmd.PersistentFlags().StringVar(&filePath, "input", "", "json file containing the cards data")
+	RootCmd.PersistentFlags().StringVar(&filePath, "input", "", "json file containing the cards data: a .json, .gz, .zst or .zip file, a directory of per-card files or - for the standard input")
+	RootCmd.PersistentFlags().StringVar(&reportPath, "report", "", "Write a JSON report of the command to this file.")
+	RootCmd.PersistentFlags().String("schema", schema.VERSION_AUTO, "Version of the card definition format: auto, v1 or v2.")
+	RootCmd.PersistentFlags().String("backup-dir", DEFAULT_BACKUP_FOLDER, "Destination folder of the backups.")
+	RootCmd.PersistentFlags().String("backup-engine", BACKUP_ENGINE_NATIVE, "Backup engine: native or mongodump.")
+	RootCmd.PersistentFlags().String("backup-format", db.EXPORT_FORMAT_BSON, "Format of the native backups: bson (mongorestore compatible) or json (extended JSON).")
+	RootCmd.PersistentFlags().Int("keep-last", 0, "Retention policy: keep the last n backups.")
+	RootCmd.PersistentFlags().Int("keep-daily", 0, "Retention policy: keep the last backup of each day for the last n days.")
+	RootCmd.PersistentFlags().Int("keep-weekly", 0, "Retention policy: keep the last backup of each week for the last n weeks.")
+	RootCmd.PersistentFlags().String("uuid-strategy", db.UUID_STRATEGY_NAME, "Identity of the cards and variations: name (English name) or ingameid (IngameId and VariationId).")
+	RootCmd.PersistentFlags().StringSlice("live-sets", []string{"BaseSet"}, "Availabilities of the released variations.")
+	RootCmd.PersistentFlags().Bool("include-non-collectible", false, "Keep the variations that are not collectible.")
+	RootCmd.PersistentFlags().StringSlice("include-cards", nil, "IngameIds of the cards always kept, released or not.")
+	RootCmd.PersistentFlags().StringSlice("exclude-cards", nil, "IngameIds of the cards always removed.")
+	RootCmd.PersistentFlags().Bool("filter-report", false, "Log every card and variation removed by the release filter.")
+	RootCmd.PersistentFlags().String("rules", "", "YAML or JSON file of normalization rules, replacing normalize.rules of the configuration file. The default rules are still applied first.")
+	viper.BindEnv("mongo.password", PASSWORD_ENV)
+	viper.BindPFlag("input.schema", RootCmd.PersistentFlags().Lookup("schema"))
+	viper.BindPFlag("backup.dir", RootCmd.PersistentFlags().Lookup("backup-dir"))
+	viper.BindPFlag("backup.engine", RootCmd.PersistentFlags().Lookup("backup-engine"))
+	viper.BindPFlag("backup.format", RootCmd.PersistentFlags().Lookup("backup-format"))
+	viper.BindPFlag("backup.retention.keepLast", RootCmd.PersistentFlags().Lookup("keep-last"))
+	viper.BindPFlag("backup.retention.keepDaily", RootCmd.PersistentFlags().Lookup("keep-daily"))
+	viper.BindPFlag("backup.retention.keepWeekly", RootCmd.PersistentFlags().Lookup("keep-weekly"))
+	viper.BindPFlag("filter.liveSets", RootCmd.PersistentFlags().Lookup("live-sets"))
+	viper.BindPFlag("filter.includeNonCollectible", RootCmd.PersistentFlags().Lookup("include-non-collectible"))
+	viper.BindPFlag("filter.include", RootCmd.PersistentFlags().Lookup("include-cards"))
+	viper.BindPFlag("filter.exclude", RootCmd.PersistentFlags().Lookup("exclude-cards"))
+	viper.BindPFlag("filter.report", RootCmd.PersistentFlags().Lookup("filter-report"))
+	viper.BindPFlag("normalize.file", RootCmd.PersistentFlags().Lookup("rules"))
+	viper.BindPFlag("uuid.strategy", RootCmd.PersistentFlags().Lookup("uuid-strategy"))
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	//RootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
@@ -89,4 +161,5 @@ func initConfig() {
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Println("Using config file:", viper.ConfigFileUsed())
 	}
+	repo.UUIDStrategy = viper.GetString("uuid.strategy")
 }
@@ -1,152 +1,256 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	db "github.com/GwentAPI/manipulator/database"
 	"github.com/GwentAPI/manipulator/models"
+	"github.com/GwentAPI/manipulator/validation"
+	"github.com/spf13/viper"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"time"
 )
 
-const BACKUP_FOLDER string = "./backup/"
+const DEFAULT_BACKUP_FOLDER string = "./backup/"
 
-var mongoDBAuthentication db.MongoConnectionSettings
+// Name of the folders created under the backup folder, one per backup.
+const BACKUP_TIME_FORMAT string = "2006-01-02T15-04-05.000"
 
-func backupDb(host string) error {
+type backupSnapshot struct {
+	Name string
+	Path string
+	Time time.Time
+	// The manifest of the backup reports a failure, or can't be read.
+	Failed bool
+}
+
+// backupFolder returns the destination of the backups, set by --backup-dir or the backup.dir config key.
+func backupFolder() string {
+	return viper.GetString("backup.dir")
+}
+
+// createBackup backups the databases described by mongoDBAuthentication.
+// inputPath is the card definition file that triggered the backup, if any.
+// A manifest describing the backup is written even if the backup failed.
+func createBackup(inputPath string) (backupSnapshot, error) {
 	t := time.Now()
-	format := "2006-01-02T15-04-05.000"
-	cmd := "mongodump"
-	args := []string{"--host", host,"--gzip", "--out", BACKUP_FOLDER + t.Format(format) + "/"}
-	if err := exec.Command(cmd, args...).Run(); err != nil {
-		return fmt.Errorf("Error while creating backup: %s", err)
+	snapshot := backupSnapshot{
+		Name: t.Format(BACKUP_TIME_FORMAT),
+		Time: t,
 	}
-	log.Println("Database backup created.")
-	return nil
+	snapshot.Path = filepath.Join(backupFolder(), snapshot.Name)
+	currentReport.Backup = snapshot.Path
+	manifest := BackupManifest{
+		CreatedAt:          t.UTC(),
+		ManipulatorVersion: Version,
+		Hosts:              mongoDBAuthentication.Host,
+		Database:           mongoDBAuthentication.Db,
+		Engine:             viper.GetString("backup.engine"),
+		Format:             viper.GetString("backup.format"),
+		Status:             BACKUP_STATUS_SUCCESS,
+	}
+	if len(inputPath) > 0 {
+		input, err := newManifestInput(inputPath)
+		if err != nil {
+			return snapshot, fmt.Errorf("Error while hashing the input file: %s", err)
+		}
+		manifest.Input = input
+	}
+
+	var err error
+	switch manifest.Engine {
+	case BACKUP_ENGINE_NATIVE:
+		manifest.Database, manifest.Collections, err = backupNative(snapshot.Path, manifest.Format)
+	case BACKUP_ENGINE_MONGODUMP:
+		if manifest.Format != db.EXPORT_FORMAT_BSON {
+			err = fmt.Errorf("The %s backup engine only supports the %s format", BACKUP_ENGINE_MONGODUMP, db.EXPORT_FORMAT_BSON)
+			break
+		}
+		err = backupMongodump(snapshot.Path)
+		if err == nil {
+			manifest.Collections, err = countBackupDocuments(snapshot.Path)
+		}
+	default:
+		err = fmt.Errorf("Unknown backup engine: %s", manifest.Engine)
+	}
+	if err != nil {
+		manifest.Status = BACKUP_STATUS_FAILED
+		manifest.Error = err.Error()
+	}
+	if manifestErr := writeManifest(snapshot.Path, manifest); manifestErr != nil && err == nil {
+		err = fmt.Errorf("Error while writing the backup manifest: %s", manifestErr)
+	}
+	return snapshot, err
 }
 
-func backupWithAuthentication(host, userName, password, authDB string, useSSL bool) error {
-	t := time.Now()
-	format := "2006-01-02T15-04-05.000"
+func backupMongodump(out string) error {
 	cmd := "mongodump"
-	args := []string{"--host", host, "-u", userName, "-p", password, "--authenticationDatabase", authDB, "--gzip", "--out", BACKUP_FOLDER + t.Format(format) + "/"}
-	if useSSL {
-		args = append(args, "--ssl")
+	args, cleanup, err := mongoToolArgs()
+	if err != nil {
+		return err
 	}
-	if err := exec.Command(cmd, args...).Run(); err != nil {
-		return fmt.Errorf("Error while creating backup: %s", err)
+	defer cleanup()
+	args = append(args, "--gzip", "--out", out)
+	if output, err := exec.Command(cmd, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("Error while creating backup: %s\n%s", err, output)
 	}
 	log.Println("Database backup created.")
 	return nil
 }
 
-func parseData() (*DataContainer, error) {
-	log.Println("Reading file...")
-	file, err := os.Open(filePath)
+// restoreBackup restores a backup in the BSON format with mongorestore.
+// The collections found in the backup are dropped before being restored.
+// A backup whose manifest doesn't report a success is only restored with force.
+// checkRestorable returns an error if the backup can't be restored with mongorestore: its manifest
+// reports a failure (unless force is set), it isn't in the BSON format, it doesn't have any data,
+// or mongorestore isn't installed.
+func checkRestorable(snapshot backupSnapshot, force bool) error {
+	manifest, err := readManifest(snapshot.Path)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if manifest != nil && manifest.Status != BACKUP_STATUS_SUCCESS {
+		if !force {
+			return fmt.Errorf("Backup %s is %s (%s) and may be partial, use --force to restore it anyway", snapshot.Name, manifest.Status, manifest.Error)
+		}
+		log.Printf("WARNING: restoring backup %s whose status is %s", snapshot.Name, manifest.Status)
+	}
+	if manifest != nil && len(manifest.Format) > 0 && manifest.Format != db.EXPORT_FORMAT_BSON {
+		return fmt.Errorf("Backup %s is in the %s format and can't be restored with mongorestore", snapshot.Name, manifest.Format)
+	}
+	database := "*"
+	if len(mongoDBAuthentication.Db) > 0 {
+		database = mongoDBAuthentication.Db
+	}
+	files, err := filepath.Glob(filepath.Join(snapshot.Path, database, "*.bson.gz"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("Backup %s doesn't contain any data to restore", snapshot.Name)
+	}
+	if _, err := exec.LookPath("mongorestore"); err != nil {
+		return fmt.Errorf("mongorestore is required to restore a backup: %s", err)
 	}
-	defer file.Close()
+	return nil
+}
 
-	var definition map[string]models.GwentCard
-	groups := make(map[string]struct{})
-	rarities := make(map[string]struct{})
-	factions := make(map[string]struct{})
-	categories := make(map[string]struct{})
-	dec := json.NewDecoder(file)
+// restoreBackup restores a backup checked by checkRestorable.
+func restoreBackup(snapshot backupSnapshot) error {
+	cmd := "mongorestore"
+	args, cleanup, err := mongoToolArgs()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	args = append(args, "--gzip", "--drop")
+	if len(mongoDBAuthentication.Db) > 0 {
+		args = append(args, "--nsInclude", mongoDBAuthentication.Db+".*")
+	}
+	args = append(args, "--dir", snapshot.Path)
+	if output, err := exec.Command(cmd, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("Error while restoring backup: %s\n%s", err, output)
+	}
+	log.Println("Database backup restored.")
+	return nil
+}
 
-	if err := dec.Decode(&definition); err != nil {
+// listBackups returns the backups found under the backup folder, oldest first.
+// Folders that weren't created by manipulator are ignored, and so are the failed
+// backups unless includeFailed is set.
+func listBackups(includeFailed bool) ([]backupSnapshot, error) {
+	entries, err := ioutil.ReadDir(backupFolder())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
 		return nil, err
 	}
-
-	for k := range definition {
-		if deleteUnreleased(definition, k) {
+	var snapshots []backupSnapshot
+	for _, entry := range entries {
+		if !entry.IsDir() {
 			continue
 		}
-		renameScoiatael(definition, k)
-		collectGroup(groups, definition[k])
-		collectRarity(rarities, definition[k])
-		collectFaction(factions, definition[k])
-		collectCategories(categories, definition[k])
-	}
-	container := &DataContainer{
-		Cards:      definition,
-		Groups:     groups,
-		Categories: categories,
-		Rarities:   rarities,
-		Factions:   factions,
+		t, err := time.ParseInLocation(BACKUP_TIME_FORMAT, entry.Name(), time.Local)
+		if err != nil {
+			continue
+		}
+		snapshot := backupSnapshot{
+			Name: entry.Name(),
+			Path: filepath.Join(backupFolder(), entry.Name()),
+			Time: t,
+		}
+		manifest, err := readManifest(snapshot.Path)
+		snapshot.Failed = err != nil || manifest != nil && manifest.Status != BACKUP_STATUS_SUCCESS
+		if snapshot.Failed && !includeFailed {
+			log.Println("Ignoring failed backup: ", snapshot.Name)
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
 	}
-
-	return container, nil
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+	return snapshots, nil
 }
 
-func deleteUnreleased(input map[string]models.GwentCard, key string) bool {
-	// Released is an artificial field that was added to the file
-	// We do check it but we still verify each individual variation.
-	if !input[key].Released {
-		delete(input, key)
-		return true
-	}
-	for variationKey, variationValue := range input[key].Variations {
-		// Not BaseSet or not collectible, therefore we want to delete it
-		// since the API only support BaseSet atm.
-		if variationValue.Availability != "BaseSet" || !variationValue.Collectible {
-			// Safety to check if a new set is found that wasn't released yet.
-			// We don't data-mine and we are only interest in the already released card, however they should
-			// Be reported in the case that something changed in the file and it needs to be corrected in the code
-			// Or to warn of an incoming new set to prepare for it.
-			if variationValue.Availability != "NonOwnable" && variationValue.Availability != "Tutorial" {
-				log.Println("Variation not from BaseSet reported.")
-				log.Println("Card: ", input[key].Name["en-US"])
-			}
-			delete(input[key].Variations, variationKey)
+// findBackup returns the backup with the given name (its timestamp), even if it failed.
+func findBackup(name string) (backupSnapshot, error) {
+	snapshots, err := listBackups(true)
+	if err != nil {
+		return backupSnapshot{}, err
+	}
+	name = filepath.Base(name)
+	for _, snapshot := range snapshots {
+		if snapshot.Name == name {
+			return snapshot, nil
 		}
 	}
+	return backupSnapshot{}, fmt.Errorf("Backup not found: %s", name)
+}
 
-	// If a variation was deleted and that it resulted in a card having no variation, we delete the card as well
-	// since there's no associated art, rarity, etc.
-	if len(input[key].Variations) == 0 {
-		delete(input, key)
-		return true
+// parseData reads a card definition (see streamData) and keeps the released cards in memory.
+func parseData(path string, validator *validation.Validator) (*DataContainer, error) {
+	cards := make(map[string]models.GwentCard)
+	container, err := streamData(path, validator, func(key string, card models.GwentCard) error {
+		cards[key] = card
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return false
+	container.Cards = cards
+	return container, nil
 }
 
 func collectGroup(groups map[string]struct{}, input models.GwentCard) {
-	if _, ok := groups[input.Group]; !ok {
+	if _, ok := groups[input.Group]; !ok && len(input.Group) > 0 {
 		groups[input.Group] = struct{}{}
 	}
 }
 
 func collectRarity(rarities map[string]struct{}, input models.GwentCard) {
 	for _, variation := range input.Variations {
-		if _, ok := rarities[variation.Rarity]; !ok {
+		if _, ok := rarities[variation.Rarity]; !ok && len(variation.Rarity) > 0 {
 			rarities[variation.Rarity] = struct{}{}
 		}
 	}
 }
 
 func collectFaction(factions map[string]struct{}, input models.GwentCard) {
-	if _, ok := factions[input.Faction]; !ok {
+	if _, ok := factions[input.Faction]; !ok && len(input.Faction) > 0 {
 		factions[input.Faction] = struct{}{}
 	}
 }
 
 func collectCategories(categories map[string]struct{}, input models.GwentCard) {
 	for _, category := range input.Categories {
-		if _, ok := categories[category]; !ok {
+		if _, ok := categories[category]; !ok && len(category) > 0 {
 			categories[category] = struct{}{}
 		}
 	}
 }
-
-// The data file doesn't have Scoia'tael spelled correctly, so we rename it.
-func renameScoiatael(input map[string]models.GwentCard, k string) {
-	if input[k].Faction == "Scoiatael" {
-		tmp := input[k]
-		tmp.Faction = "Scoia'tael"
-		input[k] = tmp
-	}
-}
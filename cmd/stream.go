@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/GwentAPI/manipulator/models"
+	"github.com/GwentAPI/manipulator/schema"
+	"github.com/GwentAPI/manipulator/validation"
+	"github.com/spf13/viper"
+	"io"
+	"log"
+)
+
+// cardDecoder decodes a card definition card by card, so the whole definition
+// never has to be held in memory.
+type cardDecoder struct {
+	decoder *json.Decoder
+	input   *countingReader
+	// Schema version of the cards, detected from the first card if it is schema.VERSION_AUTO.
+	version string
+	// Key of the card the version was detected from.
+	detectedFrom string
+	started      bool
+	done         bool
+}
+
+// countingReader counts the bytes read from the input.
+type countingReader struct {
+	io.Reader
+	count int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// DecodeError is an error of the card definition, located by its byte offset and by the key of its card.
+type DecodeError struct {
+	Offset int64
+	Card   string
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	if len(e.Card) == 0 {
+		return fmt.Sprintf("Invalid card definition at byte %d: %s", e.Offset, e.Err)
+	}
+	return fmt.Sprintf("Invalid card %q at byte %d: %s", e.Card, e.Offset, e.Err)
+}
+
+func newCardDecoder(r io.Reader, version string) *cardDecoder {
+	input := &countingReader{Reader: r}
+	return &cardDecoder{decoder: json.NewDecoder(input), input: input, version: version}
+}
+
+// offset is the position of the decoder in the input: the bytes read minus the ones still buffered.
+func (d *cardDecoder) offset() int64 {
+	if buffered, ok := d.decoder.Buffered().(interface {
+		Len() int
+	}); ok {
+		return d.input.count - int64(buffered.Len())
+	}
+	return d.input.count
+}
+
+// decodedCard is a card of the definition: its key, its content as found in the definition
+// in the schema version of the definition, and its content converted to the canonical model.
+type decodedCard struct {
+	key     string
+	raw     json.RawMessage
+	version string
+	card    models.GwentCard
+}
+
+// next returns the next card, or io.EOF after the last one. When the content of the card
+// can't be decoded, the card is returned with the error and the next card can be read.
+func (d *cardDecoder) next() (decodedCard, error) {
+	var decoded decodedCard
+	if d.done {
+		return decoded, io.EOF
+	}
+	if !d.started {
+		d.started = true
+		if err := d.delim('{'); err != nil {
+			return decoded, err
+		}
+	}
+	if !d.decoder.More() {
+		if err := d.delim('}'); err != nil {
+			return decoded, err
+		}
+		d.done = true
+		return decoded, io.EOF
+	}
+
+	offset := d.offset()
+	token, err := d.decoder.Token()
+	if err != nil {
+		return decoded, &DecodeError{Offset: offset, Err: unexpectedEOF(err)}
+	}
+	key, ok := token.(string)
+	if !ok {
+		return decoded, &DecodeError{Offset: offset, Err: fmt.Errorf("expected a card key, found %v", token)}
+	}
+	decoded.key = key
+	offset = d.offset()
+	if err := d.decoder.Decode(&decoded.raw); err != nil {
+		return decoded, &DecodeError{Offset: offset, Card: key, Err: unexpectedEOF(err)}
+	}
+	if d.version == schema.VERSION_AUTO {
+		if d.version, err = schema.Detect(decoded.raw); err != nil {
+			return decoded, &DecodeError{Offset: offset, Card: key, Err: err}
+		}
+		d.detectedFrom = key
+		log.Printf("Schema %s detected from card %q", d.version, key)
+	}
+	// Every card of a detected definition must match the version of the first one.
+	if len(d.detectedFrom) > 0 {
+		if err := schema.Check(d.version, decoded.raw); err != nil {
+			err = fmt.Errorf("%s, but the schema %s was detected from card %q: use --schema to set the version of the definition", err, d.version, d.detectedFrom)
+			return decoded, &DecodeError{Offset: offset, Card: key, Err: err}
+		}
+	}
+	decoded.version = d.version
+	if decoded.card, err = schema.Decode(d.version, decoded.raw); err != nil {
+		return decoded, &DecodeError{Offset: offset, Card: key, Err: err}
+	}
+	return decoded, nil
+}
+
+// delim reads the opening or closing brace of the definition.
+func (d *cardDecoder) delim(expected json.Delim) error {
+	offset := d.offset()
+	token, err := d.decoder.Token()
+	if err != nil {
+		return &DecodeError{Offset: offset, Err: unexpectedEOF(err)}
+	}
+	if token != expected {
+		return &DecodeError{Offset: offset, Err: fmt.Errorf("expected %s, found %v", expected, token)}
+	}
+	return nil
+}
+
+// unexpectedEOF distinguishes a truncated definition from the end of the cards.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// streamData reads a card definition (see openInput) card by card. Every card is normalized,
+// then filtered, and the released cards are passed to handle as soon as they are decoded. The returned container holds
+// the generic collections and the filter and normalization reports, but not the cards.
+//
+// With a validator, the released cards are validated once normalized and their findings are
+// returned in the container. After the first error, the cards are still validated but no longer
+// passed to handle: the caller must check the findings before using what it was handed.
+func streamData(path string, validator *validation.Validator, handle func(key string, card models.GwentCard) error) (*DataContainer, error) {
+	log.Println("Reading file...")
+	version, err := schemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	rules, err := currentNormalizationRules()
+	if err != nil {
+		return nil, err
+	}
+	file, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	container := &DataContainer{
+		Groups:     make(map[string]struct{}),
+		Rarities:   make(map[string]struct{}),
+		Factions:   make(map[string]struct{}),
+		Categories: make(map[string]struct{}),
+	}
+	filter := currentReleaseFilter()
+	normalizer := newNormalizer(rules)
+	decoder := newCardDecoder(file, version)
+	for {
+		decoded, err := decoder.next()
+		if err == io.EOF {
+			break
+		}
+		// A card read but not decoded is a finding: the next cards can still be read.
+		if err != nil && validator != nil && len(decoded.raw) > 0 {
+			validator.InvalidCard(decoded.key, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// The validation and the filter see the normalized values. Every card is checked,
+		// released or not, the checks across cards only concern the released ones.
+		card := decoded.card
+		normalizer.normalize(&card)
+		if validator != nil {
+			validator.CheckCard(decoded.key, decoded.raw, decoded.version, card)
+		}
+		kept, filtered := filter.card(&card)
+		container.Filtered = append(container.Filtered, filtered...)
+		if !kept {
+			continue
+		}
+		if validator != nil {
+			validator.RecordCard(decoded.key, decoded.version, card)
+			if validator.HasErrors() {
+				continue
+			}
+		}
+		collectGroup(container.Groups, card)
+		collectRarity(container.Rarities, card)
+		collectFaction(container.Factions, card)
+		collectCategories(container.Categories, card)
+		if err := handle(decoded.key, card); err != nil {
+			return nil, err
+		}
+	}
+
+	sortFilteredItems(container.Filtered)
+	logFilterReport(container.Filtered)
+	container.Normalized = normalizer.results()
+	if validator != nil {
+		container.Findings = validator.Finish()
+	}
+	return container, nil
+}
+
+// schemaVersion returns the version given by --schema, schema.VERSION_AUTO to detect it.
+func schemaVersion() (string, error) {
+	version := viper.GetString("input.schema")
+	if version != schema.VERSION_AUTO {
+		if _, err := schema.Model(version); err != nil {
+			return "", err
+		}
+	}
+	return version, nil
+}
@@ -1,15 +1,21 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	db "github.com/GwentAPI/manipulator/database"
+	"github.com/GwentAPI/manipulator/validation"
 	"github.com/spf13/cobra"
+	"gopkg.in/mgo.v2"
 	"log"
-	"strings"
+	"sort"
 	"time"
 )
 
 var repo db.ReposClient
+var updateDryRun bool
+var updatePrune bool
+var updateAtomic bool
 
 // updateCmd represents the update command
 var updateCmd = &cobra.Command{
@@ -18,30 +24,77 @@ var updateCmd = &cobra.Command{
 	Long: `Update the GwentAPI mongoDB database.
 
 It will override all data already present and will
-ensure that indexes are valid.`,
+ensure that indexes are valid.
+
+With --dry-run, the database is only read: the changes that the update
+would make are reported, nothing is written and no backup is created.
+It can't be combined with --atomic.
+
+With --prune, the documents that are no longer produced by the input file
+are deleted from the cards, variations, groups, rarities, factions and
+categories collections.
+
+Every reference of the cards to a faction, group, category or rarity is
+checked before anything is written: the update is aborted if one is missing
+or empty, unless --allow-dangling is used.
+
+The cards renamed since the last update (same IngameId, other English name)
+are listed and recorded in the card_aliases collection, with their old UUID
+and art slug.
+
+With --atomic, the live collections are copied to staging collections
+(cards_next...) which are updated and validated, then swapped with the live
+collections. The live collections are kept as the previous generation
+(cards_prev...), see the rollback command.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		start := time.Now()
-		if mongoDBAuthentication.Host == nil {
-			mongoDBAuthentication.Host = []string{"localhost"}
+		if updateDryRun && updateAtomic {
+			return errors.New("--dry-run can't be combined with --atomic: the staging collections would be written")
+		}
+		if err := resolveMongoSettings(cmd); err != nil {
+			return err
 		}
-		result, err := parseData()
+		// Unlike artwork, update keeps every released card: the checks across cards
+		// must pass before anything is written.
+		var result *DataContainer
+		err := timePhase("parse", func() (err error) {
+			result, err = parseData(filePath, validation.NewValidator(validationRules()))
+			return err
+		})
 		if err != nil {
-			return fmt.Errorf("Error while parsing the data: %s", err)
+			return withExitCode(EXIT_INPUT, fmt.Errorf("Error while parsing the data: %s", err))
 		}
-		flattenedHost := strings.Join(mongoDBAuthentication.Host[:], ",")
-		if len(mongoDBAuthentication.Username) > 0 {
-			if err := backupWithAuthentication(flattenedHost, mongoDBAuthentication.Username, mongoDBAuthentication.Password, mongoDBAuthentication.AuthenticationDatabase, mongoDBAuthentication.UseSSL); err != nil {
-				return err
-			}
-		} else {
-			if err := backupDb(flattenedHost); err != nil {
+		if err := checkFindings(filePath, result.Findings); err != nil {
+			return err
+		}
+		currentReport.recordData(result, len(result.Cards), countVariations(result.Cards))
+		if err := repo.CheckUUIDStrategy(result.Cards); err != nil {
+			return withExitCode(EXIT_INPUT, err)
+		}
+		if err := checkReferences(result); err != nil {
+			return withExitCode(EXIT_INPUT, err)
+		}
+		if updateDryRun {
+			if err := dryRunUpdate(result); err != nil {
 				return err
 			}
+			log.Printf("Finished in %s", time.Since(start))
+			return nil
+		}
+		err = timePhase("backup", func() error {
+			_, err := createBackup(filePath)
+			return err
+		})
+		if err != nil {
+			return withExitCode(EXIT_BACKUP, err)
 		}
 		dataContainer = result
 		if err := updateDb(dataContainer); err != nil {
 			return err
 		}
+		if err := pruneBackups(false); err != nil {
+			return err
+		}
 		elapsed := time.Since(start)
 		log.Printf("Finished in %s", elapsed)
 		return nil
@@ -55,38 +108,217 @@ func init() {
 
 	// Cobra supports Persistent Flags which will work for this command
 	// and all subcommands, e.g.:
-	mongoDBAuthentication = db.MongoConnectionSettings{
-		Timeout: 15 * time.Second,
-	}
-
-	updateCmd.Flags().StringVar(&mongoDBAuthentication.Username, "u", "", "Username used for mongoDB authentication.")
-	updateCmd.Flags().StringVar(&mongoDBAuthentication.AuthenticationDatabase, "authenticationDatabase", "", "Authentication database for mongoDB.")
-	updateCmd.Flags().StringVar(&mongoDBAuthentication.Password, "p", "", "User password for mongoDB authentication.")
-	updateCmd.PersistentFlags().StringSliceVar(&mongoDBAuthentication.Host, "host", []string{"localhost"}, "List of mongoDB server addresses on standard mongoDB port.")
-	updateCmd.PersistentFlags().BoolVar(&mongoDBAuthentication.UseSSL, "ssl", false, "Set to true if you require SSL to connect to the database")
-	updateCmd.PersistentFlags().StringVar(&mongoDBAuthentication.Db, "db", "", "Use default mongoDb database if not specified (default test).")
+	updateCmd.Flags().AddFlagSet(mongoFlags)
+	updateCmd.Flags().BoolVar(&updateDryRun, "dry-run", false, "Report the changes without writing anything.")
+	updateCmd.Flags().BoolVar(&updateAtomic, "atomic", false, "Update staging collections and swap them with the live collections once validated.")
+	updateCmd.Flags().BoolVar(&updatePrune, "prune", false, "Delete the documents that are no longer in the input file.")
+	updateCmd.Flags().BoolVar(&repo.AllowDangling, "allow-dangling", false, "Only warn about the references to missing factions, groups, categories and rarities.")
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// updateCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
 func updateDb(container *DataContainer) error {
+	if updateDryRun {
+		return errors.New("Refusing to write to the database during a dry run")
+	}
 	log.Println("Attempting to establish mongoDB session...")
 	session, err := repo.CreateSession(mongoDBAuthentication)
 	if err != nil {
-		return fmt.Errorf("Failed to establish mongoDB connection:: %s", err)
+		return withExitCode(EXIT_CONNECTION, fmt.Errorf("Failed to establish mongoDB connection: %s", err))
 	}
 	defer session.Close()
 	database := session.DB("")
+	// target is the client writing the update: it uses the staging collections with --atomic.
+	target := repo
+	if updateAtomic {
+		log.Println("Copying the live collections to the staging collections...")
+		err := timePhase("staging", func() error {
+			return repo.PrepareStaging(database, db.GwentCollections)
+		})
+		if err != nil {
+			return err
+		}
+		target.CollectionSuffix = db.STAGING_SUFFIX
+	}
+	// The renames are detected before the cards are overwritten.
+	renames, err := target.DetectRenames(database, "cards", container.Cards)
+	if err != nil {
+		return err
+	}
 	log.Println("Upserting a bunch of collections...")
-	repo.InsertGenericCollection(database, "groups", container.Groups)
-	repo.InsertGenericCollection(database, "rarities", container.Rarities)
-	repo.InsertGenericCollection(database, "factions", container.Factions)
-	repo.InsertGenericCollection(database, "categories", container.Categories)
+	err = timePhase("generic collections", func() error {
+		for _, generic := range container.GenericCollections() {
+			result, err := target.InsertGenericCollection(database, generic.Name, generic.Values)
+			if err != nil {
+				return err
+			}
+			recordWrite(generic.Name, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 	log.Println("Upserting cards...")
-	repo.InsertCard(database, "cards", container.Cards)
+	err = timePhase("cards", func() error {
+		result, err := target.InsertCard(database, "cards", container.Cards)
+		recordWrite("cards", result)
+		return err
+	})
+	if err != nil {
+		return err
+	}
 	log.Println("Upserting variations...")
-	repo.InsertVariation(database, "variations", container.Cards)
+	err = timePhase("variations", func() error {
+		result, err := target.InsertVariation(database, "variations", container.Cards)
+		recordWrite("variations", result)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if updatePrune {
+		err := timePhase("prune", func() error {
+			return pruneDb(target, database, container)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if updateAtomic {
+		log.Println("Validating the staging collections...")
+		err := timePhase("validation", func() error {
+			return validateStaging(target, database, container)
+		})
+		if err != nil {
+			log.Println("Invalid staging collections, the live collections were not modified.")
+			return err
+		}
+		log.Println("Swapping the staging collections with the live collections...")
+		err = timePhase("swap", func() error {
+			return repo.SwapStaging(database, db.GwentCollections)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(renames) > 0 {
+		printRenames(renames)
+		if err := repo.InsertRenameAliases(database, "cards", "variations", renames); err != nil {
+			return err
+		}
+	}
 	log.Println("Done")
 	return nil
 }
+
+func printRenames(renames []db.CardRename) {
+	fmt.Printf("%d renamed card(s):\n", len(renames))
+	for _, rename := range renames {
+		fmt.Printf("  %s -> %s (IngameId %s)\n", rename.OldName, rename.NewName, rename.IngameId)
+	}
+}
+
+// checkReferences fails if a card references a faction, group, category or rarity
+// that won't exist, unless --allow-dangling is used.
+func checkReferences(container *DataContainer) error {
+	collections := make(map[string]map[string]struct{})
+	for _, generic := range container.GenericCollections() {
+		collections[generic.Name] = generic.Values
+	}
+	dangling := db.FindDanglingReferences(container.Cards, collections)
+	if len(dangling) == 0 {
+		return nil
+	}
+	if !repo.AllowDangling {
+		return fmt.Errorf("Invalid input file: %s", &db.DanglingReferencesError{References: dangling})
+	}
+	for _, reference := range dangling {
+		log.Printf("WARNING: dangling reference, %s", reference)
+	}
+	return nil
+}
+
+// timePhase runs a phase of a command, logs its duration and records it in the report.
+func timePhase(name string, phase func() error) error {
+	start := time.Now()
+	err := phase()
+	elapsed := time.Since(start)
+	log.Printf("Phase %s took %s", name, elapsed)
+	currentReport.Phases = append(currentReport.Phases, phaseReport{Name: name, Seconds: elapsed.Seconds()})
+	return err
+}
+
+// validateStaging checks that every document produced by the container is in the staging collections.
+func validateStaging(target db.ReposClient, database *mgo.Database, container *DataContainer) error {
+	for _, generic := range container.GenericCollections() {
+		uuids, err := db.GenericUUIDs(generic.Values)
+		if err != nil {
+			return err
+		}
+		if err := target.CheckCount(database, generic.Name, uuids); err != nil {
+			return err
+		}
+	}
+	uuids, err := target.CardUUIDs(container.Cards)
+	if err != nil {
+		return err
+	}
+	if err := target.CheckCount(database, "cards", uuids); err != nil {
+		return err
+	}
+	if uuids, err = target.VariationUUIDs(container.Cards); err != nil {
+		return err
+	}
+	return target.CheckCount(database, "variations", uuids)
+}
+
+// pruneDb deletes the documents that were not produced by the container.
+// Variations are pruned first: their report is named after the cards.
+func pruneDb(target db.ReposClient, database *mgo.Database, container *DataContainer) error {
+	log.Println("Pruning the documents that are no longer in the input file...")
+	var removed []prunedCollection
+	names, err := target.PruneVariations(database, "variations", "cards", container.Cards)
+	if err != nil {
+		return err
+	}
+	removed = append(removed, prunedCollection{"variations", names})
+	names, err = target.PruneCards(database, "cards", container.Cards)
+	if err != nil {
+		return err
+	}
+	removed = append(removed, prunedCollection{"cards", names})
+	for _, generic := range container.GenericCollections() {
+		names, err := target.PruneGenericCollection(database, generic.Name, generic.Values)
+		if err != nil {
+			return err
+		}
+		removed = append(removed, prunedCollection{generic.Name, names})
+	}
+	for _, collection := range removed {
+		printPrunedCollection(collection)
+		currentReport.collection(collection.Name).Deleted = len(collection.Removed)
+	}
+	return nil
+}
+
+// recordWrite adds the documents written to a collection to the report.
+func recordWrite(name string, result db.WriteResult) {
+	collection := currentReport.collection(name)
+	collection.Matched += result.Matched
+	collection.Modified += result.Modified
+}
+
+type prunedCollection struct {
+	Name    string
+	Removed []string
+}
+
+func printPrunedCollection(collection prunedCollection) {
+	fmt.Printf("%s: %d removed\n", collection.Name, len(collection.Removed))
+	sort.Strings(collection.Removed)
+	for _, name := range collection.Removed {
+		fmt.Printf("  - %s\n", name)
+	}
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/GwentAPI/manipulator/models"
+	"github.com/GwentAPI/manipulator/validation"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"log"
+	"os"
+)
+
+var validateFormat string
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check a card definition file.",
+	Long: `Check a card definition file.
+
+Unknown fields, cards without English name, unknown factions, types and
+rarities, negative costs and duplicate IngameIds are errors. Unknown
+availabilities, invalid art URLs and missing translations are warnings.
+
+The accepted factions, types, rarities and availabilities can be configured
+with validation.factions, validation.types, validation.rarities and
+validation.availabilities in the configuration file.
+
+Every card is checked once normalized, released or not. The missing
+translations and the duplicate IngameIds are only checked among the released
+cards, as update and artwork read them. The cards are checked as they are read: update stops before
+writing anything if an error is found, artwork stops its downloads at the
+first card with an error.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		findings, err := validateFile(filePath)
+		if err != nil {
+			return withExitCode(EXIT_INPUT, err)
+		}
+		switch validateFormat {
+		case DIFF_FORMAT_JSON:
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(findings); err != nil {
+				return err
+			}
+		case DIFF_FORMAT_TEXT:
+			for _, finding := range findings {
+				fmt.Println(finding)
+			}
+			fmt.Printf("%d finding(s)\n", len(findings))
+		default:
+			return fmt.Errorf("Unknown format: %s", validateFormat)
+		}
+		if validation.HasErrors(findings) {
+			return withExitCode(EXIT_INPUT, fmt.Errorf("%s is invalid", filePath))
+		}
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(validateCmd)
+
+	validateCmd.Flags().StringVar(&validateFormat, "format", DIFF_FORMAT_TEXT, "Output format: text or json.")
+}
+
+func validationRules() validation.Rules {
+	rules := validation.DefaultRules
+	if viper.IsSet("validation.factions") {
+		rules.Factions = viper.GetStringSlice("validation.factions")
+	}
+	if viper.IsSet("validation.types") {
+		rules.Types = viper.GetStringSlice("validation.types")
+	}
+	if viper.IsSet("validation.rarities") {
+		rules.Rarities = viper.GetStringSlice("validation.rarities")
+	}
+	if viper.IsSet("validation.availabilities") {
+		rules.Availabilities = viper.GetStringSlice("validation.availabilities")
+	}
+	return rules
+}
+
+// validateFile reads a card definition as update does and returns the findings of its released cards.
+func validateFile(path string) ([]validation.Finding, error) {
+	container, err := streamData(path, validation.NewValidator(validationRules()), func(key string, card models.GwentCard) error {
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return container.Findings, nil
+}
+
+// checkFindings logs the findings of the cards read by a command.
+// The warnings are only logged, the errors stop the command.
+func checkFindings(path string, findings []validation.Finding) error {
+	errors := 0
+	for _, finding := range findings {
+		if finding.Severity == validation.SEVERITY_ERROR {
+			errors++
+		}
+		log.Println(finding)
+	}
+	if errors > 0 {
+		return withExitCode(EXIT_INPUT, fmt.Errorf("%s is invalid: %d error(s), see the validate command", path, errors))
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package compare
+
+import (
+	"github.com/GwentAPI/manipulator/models"
+	"sort"
+	"strings"
+)
+
+// Operations of a WordChange.
+const (
+	WORD_EQUAL  string = "equal"
+	WORD_INSERT string = "insert"
+	WORD_DELETE string = "delete"
+)
+
+// StrengthChange is a buff or a nerf of the strength of a card.
+type StrengthChange struct {
+	Card
+	Old int
+	New int
+}
+
+// WordChange is a run of consecutive words of a text diff with the same operation.
+type WordChange struct {
+	Op   string
+	Text string
+}
+
+// AbilityChange is a change of the English ability text of a card.
+type AbilityChange struct {
+	Card
+	Old   string
+	New   string
+	Words []WordChange
+}
+
+// FactionNotes are the patch notes of a faction.
+type FactionNotes struct {
+	Faction   string
+	New       []Card
+	Removed   []Card
+	Buffs     []StrengthChange
+	Nerfs     []StrengthChange
+	Abilities []AbilityChange
+}
+
+// Changelog are the patch notes between two definition files, grouped by faction.
+type Changelog struct {
+	Factions []FactionNotes
+}
+
+// NewChangelog compares two sets of cards, matched by their key in the definition file.
+func NewChangelog(old, new map[string]models.GwentCard) Changelog {
+	factions := make(map[string]*FactionNotes)
+	notes := func(faction string) *FactionNotes {
+		if _, ok := factions[faction]; !ok {
+			factions[faction] = &FactionNotes{Faction: faction}
+		}
+		return factions[faction]
+	}
+
+	for key, card := range new {
+		oldCard, ok := old[key]
+		if !ok {
+			notes(card.Faction).New = append(notes(card.Faction).New, newCard(key, card))
+			continue
+		}
+		faction := notes(card.Faction)
+		change := StrengthChange{Card: newCard(key, card), Old: oldCard.Strength, New: card.Strength}
+		if change.New > change.Old {
+			faction.Buffs = append(faction.Buffs, change)
+		} else if change.New < change.Old {
+			faction.Nerfs = append(faction.Nerfs, change)
+		}
+		if oldInfo, newInfo := oldCard.Info["en-US"], card.Info["en-US"]; oldInfo != newInfo {
+			faction.Abilities = append(faction.Abilities, AbilityChange{
+				Card:  newCard(key, card),
+				Old:   oldInfo,
+				New:   newInfo,
+				Words: DiffWords(oldInfo, newInfo),
+			})
+		}
+	}
+	for key, card := range old {
+		if _, ok := new[key]; !ok {
+			notes(card.Faction).Removed = append(notes(card.Faction).Removed, newCard(key, card))
+		}
+	}
+
+	changelog := Changelog{}
+	for _, faction := range factions {
+		if len(faction.New)+len(faction.Removed)+len(faction.Buffs)+len(faction.Nerfs)+len(faction.Abilities) == 0 {
+			continue
+		}
+		sortCards(faction.New)
+		sortCards(faction.Removed)
+		sort.Slice(faction.Buffs, func(i, j int) bool { return faction.Buffs[i].Name < faction.Buffs[j].Name })
+		sort.Slice(faction.Nerfs, func(i, j int) bool { return faction.Nerfs[i].Name < faction.Nerfs[j].Name })
+		sort.Slice(faction.Abilities, func(i, j int) bool { return faction.Abilities[i].Name < faction.Abilities[j].Name })
+		changelog.Factions = append(changelog.Factions, *faction)
+	}
+	sort.Slice(changelog.Factions, func(i, j int) bool {
+		return changelog.Factions[i].Faction < changelog.Factions[j].Faction
+	})
+	return changelog
+}
+
+// DiffWords returns the word-level diff of two texts, from their longest common subsequence of words.
+// The words of a change are separated by a single space.
+func DiffWords(old, new string) []WordChange {
+	a, b := strings.Fields(old), strings.Fields(new)
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var words []WordChange
+	// add merges the consecutive words of the same operation into one change.
+	add := func(op, text string) {
+		if last := len(words) - 1; last >= 0 && words[last].Op == op {
+			words[last].Text += " " + text
+			return
+		}
+		words = append(words, WordChange{Op: op, Text: text})
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			add(WORD_EQUAL, a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add(WORD_DELETE, a[i])
+			i++
+		default:
+			add(WORD_INSERT, b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		add(WORD_DELETE, a[i])
+	}
+	for ; j < len(b); j++ {
+		add(WORD_INSERT, b[j])
+	}
+	return words
+}
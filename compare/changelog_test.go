@@ -0,0 +1,118 @@
+package compare
+
+import (
+	"github.com/GwentAPI/manipulator/models"
+	"reflect"
+	"testing"
+)
+
+func TestDiffWords(t *testing.T) {
+	tests := []struct {
+		name  string
+		old   string
+		new   string
+		words []WordChange
+	}{
+		{
+			name:  "equal",
+			old:   "Deal 3 damage.",
+			new:   "Deal  3 damage.",
+			words: []WordChange{{WORD_EQUAL, "Deal 3 damage."}},
+		},
+		{
+			name:  "insert only",
+			old:   "Deal 3 damage.",
+			new:   "Deploy: Deal 3 damage.",
+			words: []WordChange{{WORD_INSERT, "Deploy:"}, {WORD_EQUAL, "Deal 3 damage."}},
+		},
+		{
+			name:  "delete only",
+			old:   "Deal 3 damage to an enemy. Spying.",
+			new:   "Deal 3 damage Spying.",
+			words: []WordChange{{WORD_EQUAL, "Deal 3 damage"}, {WORD_DELETE, "to an enemy."}, {WORD_EQUAL, "Spying."}},
+		},
+		{
+			name: "mix",
+			old:  "Deal 3 damage. Spying.",
+			new:  "Deal 4 damage to an enemy. Spying.",
+			words: []WordChange{
+				{WORD_EQUAL, "Deal"},
+				{WORD_DELETE, "3 damage."},
+				{WORD_INSERT, "4 damage to an enemy."},
+				{WORD_EQUAL, "Spying."},
+			},
+		},
+		{
+			name:  "from an empty text",
+			old:   "",
+			new:   "Spying.",
+			words: []WordChange{{WORD_INSERT, "Spying."}},
+		},
+	}
+	for _, test := range tests {
+		words := DiffWords(test.old, test.new)
+		if !reflect.DeepEqual(words, test.words) {
+			t.Errorf("%s: got %v, expected %v", test.name, words, test.words)
+		}
+	}
+}
+
+func testCard(faction, name string, strength int, info string) models.GwentCard {
+	return models.GwentCard{
+		Faction:  faction,
+		Name:     map[string]string{"en-US": name},
+		Info:     map[string]string{"en-US": info},
+		Strength: strength,
+	}
+}
+
+func TestNewChangelog(t *testing.T) {
+	old := map[string]models.GwentCard{
+		"1": testCard("Monsters", "Arachas", 3, "Deploy: Summon all copies."),
+		"2": testCard("Monsters", "Ghoul", 5, ""),
+		"3": testCard("Neutral", "Geralt", 13, ""),
+		"4": testCard("Neutral", "Roach", 4, ""),
+		"5": testCard("Skellige", "Unchanged", 6, "Deploy: Heal."),
+	}
+	new := map[string]models.GwentCard{
+		"1": testCard("Monsters", "Arachas", 4, "Deploy: Summon all copies."),
+		"2": testCard("Monsters", "Ghoul", 2, "Consume a card."),
+		"3": testCard("Neutral", "Geralt", 12, ""),
+		"5": testCard("Skellige", "Unchanged", 6, "Deploy: Heal."),
+		"6": testCard("Northern Realms", "Foltest", 10, ""),
+	}
+	changelog := NewChangelog(old, new)
+
+	var factions []string
+	for _, faction := range changelog.Factions {
+		factions = append(factions, faction.Faction)
+	}
+	// The factions without any change are left out.
+	if expected := []string{"Monsters", "Neutral", "Northern Realms"}; !reflect.DeepEqual(factions, expected) {
+		t.Fatalf("factions: got %v, expected %v", factions, expected)
+	}
+
+	monsters := changelog.Factions[0]
+	if len(monsters.Buffs) != 1 || monsters.Buffs[0].Name != "Arachas" || monsters.Buffs[0].Old != 3 || monsters.Buffs[0].New != 4 {
+		t.Errorf("Monsters: unexpected buffs %v", monsters.Buffs)
+	}
+	if len(monsters.Nerfs) != 1 || monsters.Nerfs[0].Name != "Ghoul" {
+		t.Errorf("Monsters: unexpected nerfs %v", monsters.Nerfs)
+	}
+	if len(monsters.Abilities) != 1 || monsters.Abilities[0].Name != "Ghoul" || monsters.Abilities[0].New != "Consume a card." {
+		t.Errorf("Monsters: unexpected ability changes %v", monsters.Abilities)
+	}
+
+	neutral := changelog.Factions[1]
+	if len(neutral.Nerfs) != 1 || neutral.Nerfs[0].Name != "Geralt" || len(neutral.Buffs) != 0 {
+		t.Errorf("Neutral: unexpected buffs %v and nerfs %v", neutral.Buffs, neutral.Nerfs)
+	}
+	if len(neutral.Removed) != 1 || neutral.Removed[0].Name != "Roach" {
+		t.Errorf("Neutral: unexpected removed cards %v", neutral.Removed)
+	}
+
+	northern := changelog.Factions[2]
+	if len(northern.New) != 1 || northern.New[0].Name != "Foltest" {
+		t.Errorf("Northern Realms: unexpected new cards %v", northern.New)
+	}
+}
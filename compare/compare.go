@@ -0,0 +1,165 @@
+package compare
+
+import (
+	"github.com/GwentAPI/manipulator/models"
+	"reflect"
+	"sort"
+)
+
+// Change is the change of one field of a card.
+type Change struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// Card identifies a card of a definition file.
+type Card struct {
+	Key     string `json:"key"`
+	Name    string `json:"name"`
+	Faction string `json:"faction"`
+}
+
+// CardChange lists the changes of a card present in both definition files.
+type CardChange struct {
+	Card
+	Changes []Change `json:"changes"`
+}
+
+// Report describes the differences between two definition files.
+type Report struct {
+	Added    []Card       `json:"added"`
+	Removed  []Card       `json:"removed"`
+	Modified []CardChange `json:"modified"`
+}
+
+// Cards compares two sets of cards, matched by their key in the definition file.
+func Cards(old, new map[string]models.GwentCard) Report {
+	report := Report{}
+	for key, card := range new {
+		oldCard, ok := old[key]
+		if !ok {
+			report.Added = append(report.Added, newCard(key, card))
+			continue
+		}
+		if changes := CardFields(oldCard, card); len(changes) > 0 {
+			report.Modified = append(report.Modified, CardChange{Card: newCard(key, card), Changes: changes})
+		}
+	}
+	for key, card := range old {
+		if _, ok := new[key]; !ok {
+			report.Removed = append(report.Removed, newCard(key, card))
+		}
+	}
+	sortCards(report.Added)
+	sortCards(report.Removed)
+	sort.Slice(report.Modified, func(i, j int) bool {
+		return report.Modified[i].Name < report.Modified[j].Name
+	})
+	return report
+}
+
+// CardFields returns the changes between two versions of a card.
+func CardFields(old, new models.GwentCard) []Change {
+	var changes []Change
+	compareLocales(&changes, "name", old.Name, new.Name)
+	compareLocales(&changes, "info", old.Info, new.Info)
+	compareLocales(&changes, "flavor", old.Flavor, new.Flavor)
+	compareValue(&changes, "strength", old.Strength, new.Strength)
+	compareValue(&changes, "faction", old.Faction, new.Faction)
+	compareValue(&changes, "type", old.Group, new.Group)
+	compareValue(&changes, "positions", old.Positions, new.Positions)
+	compareValue(&changes, "loyalties", old.Loyalties, new.Loyalties)
+	compareValue(&changes, "categories", old.Categories, new.Categories)
+
+	for _, key := range variationKeys(old, new) {
+		prefix := "variations." + key
+		oldVariation, inOld := old.Variations[key]
+		newVariation, inNew := new.Variations[key]
+		if !inOld || !inNew {
+			var oldValue, newValue interface{}
+			if inOld {
+				oldValue = oldVariation.Availability
+			}
+			if inNew {
+				newValue = newVariation.Availability
+			}
+			changes = append(changes, Change{Field: prefix, Old: oldValue, New: newValue})
+			continue
+		}
+		compareValue(&changes, prefix+".rarity", oldVariation.Rarity, newVariation.Rarity)
+		compareValue(&changes, prefix+".craft.standard", oldVariation.Craft.Standard, newVariation.Craft.Standard)
+		compareValue(&changes, prefix+".craft.premium", oldVariation.Craft.Premium, newVariation.Craft.Premium)
+		compareValue(&changes, prefix+".mill.standard", oldVariation.Mill.Standard, newVariation.Mill.Standard)
+		compareValue(&changes, prefix+".mill.premium", oldVariation.Mill.Premium, newVariation.Mill.Premium)
+		compareValue(&changes, prefix+".art.artist", oldVariation.Art.Artist, newVariation.Art.Artist)
+		compareValue(&changes, prefix+".art.original", oldVariation.Art.Original, newVariation.Art.Original)
+		compareValue(&changes, prefix+".art.high", oldVariation.Art.High, newVariation.Art.High)
+		compareValue(&changes, prefix+".art.medium", oldVariation.Art.Medium, newVariation.Art.Medium)
+		compareValue(&changes, prefix+".art.low", oldVariation.Art.Low, newVariation.Art.Low)
+		compareValue(&changes, prefix+".art.thumbnail", oldVariation.Art.Thumbnail, newVariation.Art.Thumbnail)
+	}
+	return changes
+}
+
+func newCard(key string, card models.GwentCard) Card {
+	return Card{Key: key, Name: card.Name["en-US"], Faction: card.Faction}
+}
+
+func sortCards(cards []Card) {
+	sort.Slice(cards, func(i, j int) bool {
+		return cards[i].Name < cards[j].Name
+	})
+}
+
+func compareValue(changes *[]Change, field string, old, new interface{}) {
+	if !reflect.DeepEqual(old, new) {
+		*changes = append(*changes, Change{Field: field, Old: old, New: new})
+	}
+}
+
+// compareLocales compares the translations of a text, locale by locale.
+func compareLocales(changes *[]Change, field string, old, new map[string]string) {
+	locales := make(map[string]struct{})
+	for locale := range old {
+		locales[locale] = struct{}{}
+	}
+	for locale := range new {
+		locales[locale] = struct{}{}
+	}
+	for _, locale := range sortedKeys(locales) {
+		oldText, inOld := old[locale]
+		newText, inNew := new[locale]
+		if inOld && inNew && oldText == newText {
+			continue
+		}
+		change := Change{Field: field + "." + locale}
+		if inOld {
+			change.Old = oldText
+		}
+		if inNew {
+			change.New = newText
+		}
+		*changes = append(*changes, change)
+	}
+}
+
+func variationKeys(old, new models.GwentCard) []string {
+	keys := make(map[string]struct{})
+	for _, key := range old.VariationKeys() {
+		keys[key] = struct{}{}
+	}
+	for _, key := range new.VariationKeys() {
+		keys[key] = struct{}{}
+	}
+	return sortedKeys(keys)
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -2,6 +2,8 @@ package database
 
 import (
 	"crypto/tls"
+	"encoding/hex"
+	"fmt"
 	"github.com/GwentAPI/manipulator/common"
 	"github.com/GwentAPI/manipulator/models"
 	"github.com/satori/go.uuid"
@@ -15,32 +17,83 @@ import (
 
 const DOMAIN string = "46bf3452-28e7-482c-9bbf-df053873b021"
 
-type ReposClient struct{}
+// Collections managed by manipulator. They are backed up, staged and rolled back together.
+var GwentCollections = []string{"cards", "variations", "groups", "rarities", "factions", "categories", ALIAS_COLLECTION}
+
+type ReposClient struct {
+	// CollectionSuffix is appended to the name of every collection read or written,
+	// it is used to load the staging collections of an atomic update.
+	CollectionSuffix string
+	// UUIDStrategy derives the UUID of the cards and variations, UUID_STRATEGY_NAME by default.
+	UUIDStrategy string
+	// AllowDangling writes the documents whose references can't be resolved instead of failing.
+	AllowDangling bool
+}
 
 type MongoConnectionSettings struct {
 	Host                   []string
 	Db                     string
 	AuthenticationDatabase string
+	AuthMechanism          string
 	Username               string
 	Password               string
+	ReplicaSet             string
+	ReadPreference         string
 	UseSSL                 bool
+	TLSCAFile              string
+	TLSCertificateKeyFile  string
+	TLSServerName          string
+	TLSInsecure            bool
 	Timeout                time.Duration
 }
 
+// UsesTLSOptions returns true if one of the TLS options is set, which implies the use of TLS.
+func (s MongoConnectionSettings) UsesTLSOptions() bool {
+	return len(s.TLSCAFile) > 0 || len(s.TLSCertificateKeyFile) > 0 || len(s.TLSServerName) > 0 || s.TLSInsecure
+}
+
+var readPreferences = map[string]mgo.Mode{
+	"primary":            mgo.Primary,
+	"primaryPreferred":   mgo.PrimaryPreferred,
+	"secondary":          mgo.Secondary,
+	"secondaryPreferred": mgo.SecondaryPreferred,
+	"nearest":            mgo.Nearest,
+}
+
 func (c ReposClient) CreateSession(authInfo MongoConnectionSettings) (*mgo.Session, error) {
 
-	tlsConfig := &tls.Config{}
+	tlsConfig, err := authInfo.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// The username of the x509 authentication is the subject of the client certificate.
+	if authInfo.AuthMechanism == MECHANISM_X509 && len(authInfo.Username) == 0 {
+		if authInfo.Username, err = authInfo.ClientCertificateSubject(); err != nil {
+			return nil, fmt.Errorf("x509 authentication: %s", err)
+		}
+	}
 
 	dialInfo := &mgo.DialInfo{
-		Addrs:    authInfo.Host,
-		Database: authInfo.Db,
-		Source:   authInfo.AuthenticationDatabase,
-		Username: authInfo.Username,
-		Password: authInfo.Password,
-		Timeout:  authInfo.Timeout,
+		Addrs:          authInfo.Host,
+		Database:       authInfo.Db,
+		Source:         authInfo.AuthenticationDatabase,
+		Mechanism:      authInfo.AuthMechanism,
+		Username:       authInfo.Username,
+		Password:       authInfo.Password,
+		ReplicaSetName: authInfo.ReplicaSet,
+		Timeout:        authInfo.Timeout,
+	}
+
+	mode := mgo.Strong
+	if len(authInfo.ReadPreference) > 0 {
+		var ok bool
+		if mode, ok = readPreferences[authInfo.ReadPreference]; !ok {
+			return nil, fmt.Errorf("unknown read preference: %s", authInfo.ReadPreference)
+		}
 	}
 
-	if authInfo.UseSSL {
+	if authInfo.UseSSL || authInfo.UsesTLSOptions() {
 		dialInfo.DialServer = func(addr *mgo.ServerAddr) (net.Conn, error) {
 			conn, err := tls.Dial("tcp", addr.String(), tlsConfig)
 			return conn, err
@@ -52,19 +105,76 @@ func (c ReposClient) CreateSession(authInfo MongoConnectionSettings) (*mgo.Sessi
 		return nil, err
 	}
 	session.SetSocketTimeout(10 * time.Second)
-	session.SetMode(mgo.Strong, true)
+	session.SetMode(mode, true)
 	session.SetSafe(&mgo.Safe{WMode: "majority"})
 	return session, nil
 }
 
-func (c ReposClient) InsertGenericCollection(db *mgo.Database, collectionName string, names map[string]struct{}) {
+// WriteResult counts the documents written by the upserts of a collection.
+type WriteResult struct {
+	// Documents matched by the upserts, the inserted ones included: the BulkResult of mgo
+	// doesn't count them apart.
+	Matched int `json:"matched"`
+	// Documents whose content changed, available since MongoDB 2.6.
+	Modified int `json:"modified"`
+}
+
+func newWriteResult(result *mgo.BulkResult) WriteResult {
+	if result == nil {
+		return WriteResult{}
+	}
+	return WriteResult{Matched: result.Matched, Modified: result.Modified}
+}
+
+// Number of upserts sent to the database at once.
+const WRITE_BATCH_SIZE int = 500
+
+// batchWriter upserts the documents of a collection by batches of WRITE_BATCH_SIZE,
+// so that the documents of a collection are never all held in a single bulk operation.
+type batchWriter struct {
+	collection *mgo.Collection
+	bulk       *mgo.Bulk
+	pending    int
+	result     WriteResult
+}
+
+func newBatchWriter(collection *mgo.Collection) *batchWriter {
+	return &batchWriter{collection: collection}
+}
+
+func (w *batchWriter) upsert(selector interface{}, document interface{}) error {
+	if w.bulk == nil {
+		w.bulk = w.collection.Bulk()
+		w.bulk.Unordered()
+	}
+	w.bulk.Upsert(selector, document)
+	w.pending++
+	if w.pending < WRITE_BATCH_SIZE {
+		return nil
+	}
+	return w.flush()
+}
+
+// flush writes the pending upserts and returns the counts of every batch written.
+func (w *batchWriter) flush() error {
+	if w.pending == 0 {
+		return nil
+	}
+	result, err := w.bulk.Run()
+	written := newWriteResult(result)
+	w.result.Matched += written.Matched
+	w.result.Modified += written.Modified
+	w.bulk, w.pending = nil, 0
+	return newRepositoryError(w.collection.Name, PHASE_WRITE, err)
+}
+
+func (c ReposClient) InsertGenericCollection(db *mgo.Database, collectionName string, names map[string]struct{}) (WriteResult, error) {
+	collection := c.collection(db, collectionName)
 	domainUUID, err := uuid.FromString(DOMAIN)
 	if err != nil {
-		log.Fatal("DomainUUID error: ", err)
+		return WriteResult{}, newRepositoryError(collection.Name, PHASE_PREPARE, err)
 	}
 
-	collection := db.C(collectionName)
-
 	nameIndex := mgo.Index{
 		Key:        []string{"name"},
 		Unique:     true,
@@ -79,30 +189,30 @@ func (c ReposClient) InsertGenericCollection(db *mgo.Database, collectionName st
 		Name:       "uuid",
 	}
 
-	errNameIndex := collection.EnsureIndex(nameIndex)
-	errUuidIndex := collection.EnsureIndex(uuidIndex)
-	if errNameIndex != nil || errUuidIndex != nil {
-		log.Fatal("Error creating index: ", errNameIndex, " ", errUuidIndex)
+	if err := collection.EnsureIndex(nameIndex); err != nil {
+		return WriteResult{}, newRepositoryError(collection.Name, PHASE_INDEX, err)
+	}
+	if err := collection.EnsureIndex(uuidIndex); err != nil {
+		return WriteResult{}, newRepositoryError(collection.Name, PHASE_INDEX, err)
 	}
 
-	bulk := collection.Bulk()
-	bulk.Unordered()
+	writer := newBatchWriter(collection)
 
 	for key, _ := range names {
 		generic := models.GenericCollection{}
 
 		generic.Name = key
 		generic.Last_modified = time.Now().UTC()
-		generic.UUID = uuid.NewV5(domainUUID, key).Bytes()
+		generic.UUID = genericUUID(domainUUID, key)
 
 		selector := bson.M{"uuid": generic.UUID}
-		bulk.Upsert(selector, generic)
+		if err := writer.upsert(selector, generic); err != nil {
+			return writer.result, err
+		}
 	}
 
-	_, bulkErr := bulk.Run()
-	if bulkErr != nil {
-		log.Fatal("Error bulk upsert: ", bulkErr)
-	}
+	err = writer.flush()
+	return writer.result, err
 }
 
 func (c ReposClient) EnsureSimpleIndex(collection *mgo.Collection, key string, name string, isUnique bool) error {
@@ -116,22 +226,23 @@ func (c ReposClient) EnsureSimpleIndex(collection *mgo.Collection, key string, n
 	if err != nil {
 		log.Println("Problem with index key ", key, " with name ", name, " : ", err)
 	}
-	return err
+	return newRepositoryError(collection.Name, PHASE_INDEX, err)
 }
 
-func (c ReposClient) InsertCard(db *mgo.Database, collectionName string, cards map[string]models.GwentCard) {
+func (c ReposClient) InsertCard(db *mgo.Database, collectionName string, cards map[string]models.GwentCard) (WriteResult, error) {
+	collection := c.collection(db, collectionName)
 	domainUUID, err := uuid.FromString(DOMAIN)
 	if err != nil {
-		log.Fatal("DomainUUID error: ", err)
+		return WriteResult{}, newRepositoryError(collection.Name, PHASE_PREPARE, err)
 	}
 
-	collection := db.C(collectionName)
-
-	errNameIndex := c.EnsureSimpleIndex(collection, "name.en-US", "name.en-US", false)
-	errUuidIndex := c.EnsureSimpleIndex(collection, "uuid", "uuid", true)
-	if errNameIndex != nil || errUuidIndex != nil {
-		log.Fatal("Error creating index: ", errNameIndex, " ", errUuidIndex)
+	if err := c.EnsureSimpleIndex(collection, "name.en-US", "name.en-US", false); err != nil {
+		return WriteResult{}, err
+	}
+	if err := c.EnsureSimpleIndex(collection, "uuid", "uuid", true); err != nil {
+		return WriteResult{}, err
 	}
+	// The indexes of the other locales are not required.
 	c.EnsureSimpleIndex(collection, "name.de-DE", "name.de-DE", false)
 	c.EnsureSimpleIndex(collection, "name.fr-FR", "name.fr-FR", false)
 	c.EnsureSimpleIndex(collection, "name.pl-PL", "name.pl-PL", false)
@@ -144,92 +255,58 @@ func (c ReposClient) InsertCard(db *mgo.Database, collectionName string, cards m
 	c.EnsureSimpleIndex(collection, "name.ru-RU", "name.ru-RU", false)
 	c.EnsureSimpleIndex(collection, "name.zh-CN", "name.zh-CN", false)
 
-	bulk := collection.Bulk()
-	bulk.Unordered()
-
-	factionIDs := map[string]bson.ObjectId{}
-	groupIDs := map[string]bson.ObjectId{}
-	categoryIDs := map[string]bson.ObjectId{}
-
-	for _, v := range cards {
-		c := models.Card{
-			Name:          v.Name,
-			UUID:          uuid.NewV5(domainUUID, v.Name["en-US"]).Bytes(),
-			Group:         v.Group,
-			Faction:       v.Faction,
-			Positions:     v.Positions,
-			Last_Modified: time.Now().UTC(),
-		}
-
-		if v.Strength > 0 {
-			c.Strength = new(int)
-			*c.Strength = v.Strength
-		}
-
-		if _, ok := v.Info["en-US"]; ok {
-			c.Info = v.Info
-		}
-		if _, ok := v.Flavor["en-US"]; ok {
-			c.Flavor = v.Flavor
-		}
-
-		if len(v.Loyalties) > 0 {
-			c.Loyalties = v.Loyalties
-		}
-
-		if factionID, ok := factionIDs[v.Faction]; ok {
-			c.Faction_id = factionID
-		} else {
-			queryResult := models.GenericCollection{}
-			db.C("factions").Find(bson.M{"name": v.Faction}).Select(bson.M{"_id": 1}).One(&queryResult)
-			factionIDs[v.Faction] = queryResult.ID
-			c.Faction_id = queryResult.ID
-		}
-
-		if groupID, ok := groupIDs[v.Group]; ok {
-			c.Group_id = groupID
-		} else {
-			queryResult := models.GenericCollection{}
-			db.C("groups").Find(bson.M{"name": v.Group}).Select(bson.M{"_id": 1}).One(&queryResult)
-			groupIDs[v.Group] = queryResult.ID
-			c.Group_id = queryResult.ID
-		}
-
-		if len(v.Categories) > 0 {
-			c.Categories = new([]string)
-			*c.Categories = v.Categories
-
-			for _, category := range v.Categories {
-				if categoryID, ok := categoryIDs[category]; ok {
-					c.Categories_id = append(c.Categories_id, categoryID)
-				} else {
-					queryResult := models.GenericCollection{}
-					db.C("categories").Find(bson.M{"name": category}).Select(bson.M{"_id": 1}).One(&queryResult)
-					categoryIDs[category] = queryResult.ID
-					c.Categories_id = append(c.Categories_id, queryResult.ID)
-				}
-			}
-		} else {
+	// One query per referenced collection: a missing reference is left empty.
+	factionIDs, err := loadGenericIDs(c.collection(db, "factions"))
+	if err != nil {
+		return WriteResult{}, err
+	}
+	groupIDs, err := loadGenericIDs(c.collection(db, "groups"))
+	if err != nil {
+		return WriteResult{}, err
+	}
+	categoryIDs, err := loadGenericIDs(c.collection(db, "categories"))
+	if err != nil {
+		return WriteResult{}, err
+	}
 
+	newCard := func(v models.GwentCard, references *referenceChecker) models.Card {
+		card := c.newCard(domainUUID, v)
+		name := v.Name["en-US"]
+		card.Faction_id = references.resolve(factionIDs, name, "faction", v.Faction)
+		card.Group_id = references.resolve(groupIDs, name, "group", v.Group)
+		for _, category := range v.Categories {
+			card.Categories_id = append(card.Categories_id, references.resolve(categoryIDs, name, "category", category))
 		}
-		selector := bson.M{"uuid": c.UUID}
-		bulk.Upsert(selector, c)
+		return card
+	}
+	// Every reference is checked before the first batch is written.
+	references := referenceChecker{}
+	for _, v := range cards {
+		newCard(v, &references)
+	}
+	if err := references.check(c, collection.Name); err != nil {
+		return WriteResult{}, err
 	}
 
-	_, bulkErr := bulk.Run()
-	if bulkErr != nil {
-		log.Fatal("Error bulk card upsert: ", bulkErr)
+	writer := newBatchWriter(collection)
+	for _, v := range cards {
+		card := newCard(v, &referenceChecker{})
+		selector := bson.M{"uuid": card.UUID}
+		if err := writer.upsert(selector, card); err != nil {
+			return writer.result, err
+		}
 	}
+	err = writer.flush()
+	return writer.result, err
 }
 
-func (c ReposClient) InsertVariation(db *mgo.Database, collectionName string, cards map[string]models.GwentCard) {
+func (c ReposClient) InsertVariation(db *mgo.Database, collectionName string, cards map[string]models.GwentCard) (WriteResult, error) {
+	collection := c.collection(db, collectionName)
 	domainUUID, err := uuid.FromString(DOMAIN)
 	if err != nil {
-		log.Fatal("DomainUUID error: ", err)
+		return WriteResult{}, newRepositoryError(collection.Name, PHASE_PREPARE, err)
 	}
 
-	collection := db.C(collectionName)
-
 	cardIndex := mgo.Index{
 		Key:        []string{"card_id"},
 		Unique:     true,
@@ -244,61 +321,197 @@ func (c ReposClient) InsertVariation(db *mgo.Database, collectionName string, ca
 		Name:       "uuid",
 	}
 
-	errNameIndex := collection.EnsureIndex(cardIndex)
-	errUuidIndex := collection.EnsureIndex(uuidIndex)
-	if errNameIndex != nil || errUuidIndex != nil {
-		log.Fatal("Error creating index: ", errNameIndex, " ", errUuidIndex)
+	if err := collection.EnsureIndex(cardIndex); err != nil {
+		return WriteResult{}, newRepositoryError(collection.Name, PHASE_INDEX, err)
+	}
+	if err := collection.EnsureIndex(uuidIndex); err != nil {
+		return WriteResult{}, newRepositoryError(collection.Name, PHASE_INDEX, err)
+	}
+
+	// The cards are found by their UUID, which is derived from the card definition.
+	cardIDs, err := loadIDsByUUID(c.collection(db, "cards"))
+	if err != nil {
+		return WriteResult{}, err
+	}
+	rarityIDs, err := loadGenericIDs(c.collection(db, "rarities"))
+	if err != nil {
+		return WriteResult{}, err
 	}
 
-	bulk := collection.Bulk()
-	bulk.Unordered()
+	newVariations := func(card models.GwentCard, references *referenceChecker) []models.Variation {
+		name := card.Name["en-US"]
+		key := hex.EncodeToString(c.cardUUID(domainUUID, card))
+		cardID, ok := cardIDs[key]
+		if !ok {
+			references.dangling = append(references.dangling, DanglingReference{Card: name, Field: "card", Value: name})
+		}
+		variations := c.newVariations(domainUUID, card)
+		for i, v := range variations {
+			v.Card_id = cardID
+			v.Rarity_id = references.resolve(rarityIDs, variationName(name, v), "rarity", v.Rarity)
+			variations[i] = v
+		}
+		return variations
+	}
+	// Every reference is checked before the first batch is written.
+	references := referenceChecker{}
+	for _, card := range cards {
+		newVariations(card, &references)
+	}
+	if err := references.check(c, collection.Name); err != nil {
+		return WriteResult{}, err
+	}
 
+	writer := newBatchWriter(collection)
 	for _, card := range cards {
-		queryResult := models.Card{}
-		db.C("cards").Find(bson.M{"name.en-US": card.Name["en-US"]}).Select(bson.M{"_id": 1}).One(&queryResult)
-		artUrl := common.GetArtUrl(card.Name["en-US"])
-		//log.Println(artUrl)
-		var numVariation int = 0
-		for _, variation := range card.Variations {
-			// UUID : name + availability
-			numVariation++
-			thumbnailUrl := artUrl + "-" + strconv.Itoa(numVariation) + "-thumbnail.png"
-			mediumSizeUrl := artUrl + "-" + strconv.Itoa(numVariation) + "-medium.png"
-			originalSizeUrl := artUrl + "-" + strconv.Itoa(numVariation) + "-full.png"
-
-			v := models.Variation{
-				UUID:         uuid.NewV5(domainUUID, card.Name["en-US"]+variation.Availability).Bytes(),
-				Availability: variation.Availability,
-				Rarity:       variation.Rarity,
-				Craft: models.Cost{
-					Normal:  variation.Craft.Standard,
-					Premium: variation.Craft.Premium,
-				},
-				Mill: models.Cost{
-					Normal:  variation.Mill.Standard,
-					Premium: variation.Mill.Premium,
-				},
-				Art: models.Art{
-					FullsizeImage:   &originalSizeUrl,
-					MediumsizeImage: mediumSizeUrl,
-					ThumbnailImage:  thumbnailUrl,
-					Artist:          variation.Art.Artist,
-				},
-				Last_Modified: time.Now().UTC(),
+		for _, v := range newVariations(card, &referenceChecker{}) {
+			selector := bson.M{"uuid": v.UUID}
+			if err := writer.upsert(selector, v); err != nil {
+				return writer.result, err
 			}
-			v.Card_id = queryResult.ID
+		}
+	}
+	err = writer.flush()
+	return writer.result, err
+}
 
-			queryRarityResult := models.GenericCollection{}
-			db.C("rarities").Find(bson.M{"name": v.Rarity}).Select(bson.M{"_id": 1}).One(&queryRarityResult)
+// loadGenericIDs returns the _id of the documents of a generic collection by name.
+func loadGenericIDs(collection *mgo.Collection) (map[string]bson.ObjectId, error) {
+	var documents []models.GenericCollection
+	if err := collection.Find(nil).Select(bson.M{"_id": 1, "name": 1}).All(&documents); err != nil {
+		return nil, newRepositoryError(collection.Name, PHASE_LOOKUP, err)
+	}
+	ids := make(map[string]bson.ObjectId, len(documents))
+	for _, document := range documents {
+		ids[document.Name] = document.ID
+	}
+	return ids, nil
+}
 
-			v.Rarity_id = queryRarityResult.ID
+// loadIDsByUUID returns the _id of the documents of a collection by UUID, encoded in hexadecimal.
+func loadIDsByUUID(collection *mgo.Collection) (map[string]bson.ObjectId, error) {
+	var documents []models.GenericCollection
+	if err := collection.Find(nil).Select(bson.M{"_id": 1, "uuid": 1}).All(&documents); err != nil {
+		return nil, newRepositoryError(collection.Name, PHASE_LOOKUP, err)
+	}
+	ids := make(map[string]bson.ObjectId, len(documents))
+	for _, document := range documents {
+		ids[hex.EncodeToString(document.UUID)] = document.ID
+	}
+	return ids, nil
+}
 
-			selector := bson.M{"uuid": v.UUID}
-			bulk.Upsert(selector, v)
+// GenericUUIDs returns the UUIDs of the documents of a generic collection.
+func GenericUUIDs(names map[string]struct{}) ([][]byte, error) {
+	domainUUID, err := uuid.FromString(DOMAIN)
+	if err != nil {
+		return nil, err
+	}
+	uuids := make([][]byte, 0, len(names))
+	for name := range names {
+		uuids = append(uuids, genericUUID(domainUUID, name))
+	}
+	return uuids, nil
+}
+
+// CardUUIDs returns the UUIDs of the card documents of the cards.
+func (c ReposClient) CardUUIDs(cards map[string]models.GwentCard) ([][]byte, error) {
+	domainUUID, err := uuid.FromString(DOMAIN)
+	if err != nil {
+		return nil, err
+	}
+	uuids := make([][]byte, 0, len(cards))
+	for _, card := range cards {
+		uuids = append(uuids, c.cardUUID(domainUUID, card))
+	}
+	return uuids, nil
+}
+
+// VariationUUIDs returns the UUIDs of the variation documents of the cards.
+func (c ReposClient) VariationUUIDs(cards map[string]models.GwentCard) ([][]byte, error) {
+	domainUUID, err := uuid.FromString(DOMAIN)
+	if err != nil {
+		return nil, err
+	}
+	var uuids [][]byte
+	for _, card := range cards {
+		for _, variation := range card.Variations {
+			uuids = append(uuids, c.variationUUID(domainUUID, card, variation))
 		}
 	}
-	_, bulkErr := bulk.Run()
-	if bulkErr != nil {
-		log.Fatal("Error bulk variation upsert: ", bulkErr)
+	return uuids, nil
+}
+
+func genericUUID(domainUUID uuid.UUID, name string) []byte {
+	return uuid.NewV5(domainUUID, name).Bytes()
+}
+
+// newCard returns the card document of a card, without the references to the other collections.
+func (c ReposClient) newCard(domainUUID uuid.UUID, v models.GwentCard) models.Card {
+	card := models.Card{
+		Name:          v.Name,
+		UUID:          c.cardUUID(domainUUID, v),
+		Group:         v.Group,
+		IngameId:      v.IngameId,
+		Faction:       v.Faction,
+		Positions:     v.Positions,
+		Last_Modified: time.Now().UTC(),
+	}
+
+	if v.Strength > 0 {
+		card.Strength = new(int)
+		*card.Strength = v.Strength
+	}
+
+	if _, ok := v.Info["en-US"]; ok {
+		card.Info = v.Info
+	}
+	if _, ok := v.Flavor["en-US"]; ok {
+		card.Flavor = v.Flavor
+	}
+
+	if len(v.Loyalties) > 0 {
+		card.Loyalties = v.Loyalties
+	}
+
+	if len(v.Categories) > 0 {
+		card.Categories = new([]string)
+		*card.Categories = v.Categories
+	}
+	return card
+}
+
+// newVariations returns the variation documents of a card, without the references to the other collections.
+func (c ReposClient) newVariations(domainUUID uuid.UUID, card models.GwentCard) []models.Variation {
+	var variations []models.Variation
+	artUrl := common.GetArtUrl(card.Name["en-US"])
+	for i, key := range card.VariationKeys() {
+		variation := card.Variations[key]
+		numVariation := strconv.Itoa(i + 1)
+		thumbnailUrl := artUrl + "-" + numVariation + "-thumbnail.png"
+		mediumSizeUrl := artUrl + "-" + numVariation + "-medium.png"
+		originalSizeUrl := artUrl + "-" + numVariation + "-full.png"
+
+		variations = append(variations, models.Variation{
+			UUID:         c.variationUUID(domainUUID, card, variation),
+			Availability: variation.Availability,
+			Rarity:       variation.Rarity,
+			Craft: models.Cost{
+				Normal:  variation.Craft.Standard,
+				Premium: variation.Craft.Premium,
+			},
+			Mill: models.Cost{
+				Normal:  variation.Mill.Standard,
+				Premium: variation.Mill.Premium,
+			},
+			Art: models.Art{
+				FullsizeImage:   &originalSizeUrl,
+				MediumsizeImage: mediumSizeUrl,
+				ThumbnailImage:  thumbnailUrl,
+				Artist:          variation.Art.Artist,
+			},
+			Last_Modified: time.Now().UTC(),
+		})
 	}
+	return variations
 }
@@ -0,0 +1,310 @@
+package database
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/GwentAPI/manipulator/models"
+	"github.com/satori/go.uuid"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Fields that are not compared: identifiers, references and modification dates.
+var diffIgnoredFields = map[string]bool{
+	"ID":            true,
+	"UUID":          true,
+	"Faction_id":    true,
+	"Group_id":      true,
+	"Categories_id": true,
+	"Card_id":       true,
+	"Rarity_id":     true,
+	"Last_Modified": true,
+	"Last_modified": true,
+}
+
+// FieldChange is the change of one field of a document.
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// DocumentChange lists the changes of a document that already exists.
+type DocumentChange struct {
+	UUID    string        `json:"uuid"`
+	Name    string        `json:"name"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// CollectionDiff describes what an update would do to a collection.
+type CollectionDiff struct {
+	Collection string           `json:"collection"`
+	Added      []string         `json:"added"`
+	Modified   []DocumentChange `json:"modified"`
+	Unchanged  int              `json:"unchanged"`
+	// Documents of the collection that are not produced by the update.
+	Stale []string `json:"stale"`
+}
+
+// DiffGenericCollection compares the names to the documents of a generic collection.
+func (c ReposClient) DiffGenericCollection(db *mgo.Database, collectionName string, names map[string]struct{}) (CollectionDiff, error) {
+	diff := CollectionDiff{Collection: collectionName}
+	domainUUID, err := uuid.FromString(DOMAIN)
+	if err != nil {
+		return diff, newRepositoryError(collectionName, PHASE_PREPARE, err)
+	}
+	var existing []models.GenericCollection
+	if err := c.collection(db, collectionName).Find(nil).All(&existing); err != nil {
+		return diff, newRepositoryError(collectionName+c.CollectionSuffix, PHASE_READ, err)
+	}
+	existingByUUID := make(map[string]models.GenericCollection)
+	for _, document := range existing {
+		existingByUUID[hex.EncodeToString(document.UUID)] = document
+	}
+	produced := make(map[string]struct{})
+	for name := range names {
+		key := hex.EncodeToString(genericUUID(domainUUID, name))
+		produced[key] = struct{}{}
+		if _, ok := existingByUUID[key]; ok {
+			diff.Unchanged++
+		} else {
+			diff.Added = append(diff.Added, name)
+		}
+	}
+	for key, document := range existingByUUID {
+		if _, ok := produced[key]; !ok {
+			diff.Stale = append(diff.Stale, document.Name)
+		}
+	}
+	diff.sort()
+	return diff, nil
+}
+
+// DiffCards compares the cards to the documents of the card collection.
+func (c ReposClient) DiffCards(db *mgo.Database, collectionName string, cards map[string]models.GwentCard) (CollectionDiff, error) {
+	diff := CollectionDiff{Collection: collectionName}
+	domainUUID, err := uuid.FromString(DOMAIN)
+	if err != nil {
+		return diff, newRepositoryError(collectionName, PHASE_PREPARE, err)
+	}
+	var existing []models.Card
+	if err := c.collection(db, collectionName).Find(nil).All(&existing); err != nil {
+		return diff, newRepositoryError(collectionName+c.CollectionSuffix, PHASE_READ, err)
+	}
+	existingByUUID := make(map[string]models.Card)
+	for _, document := range existing {
+		existingByUUID[hex.EncodeToString(document.UUID)] = document
+	}
+	produced := make(map[string]struct{})
+	for _, card := range cards {
+		document, err := normalizeDocument(c.newCard(domainUUID, card))
+		if err != nil {
+			return diff, newRepositoryError(collectionName, PHASE_PREPARE, err)
+		}
+		newDocument := document.(models.Card)
+		key := hex.EncodeToString(newDocument.UUID)
+		produced[key] = struct{}{}
+		old, ok := existingByUUID[key]
+		if !ok {
+			diff.Added = append(diff.Added, card.Name["en-US"])
+			continue
+		}
+		diff.addChanges(key, card.Name["en-US"], old, newDocument)
+	}
+	for key, document := range existingByUUID {
+		if _, ok := produced[key]; !ok {
+			diff.Stale = append(diff.Stale, document.Name["en-US"])
+		}
+	}
+	diff.sort()
+	return diff, nil
+}
+
+// DiffVariations compares the variations of the cards to the documents of the variation collection.
+// cardCollectionName is used to name the variations that are no longer produced.
+func (c ReposClient) DiffVariations(db *mgo.Database, collectionName string, cardCollectionName string, cards map[string]models.GwentCard) (CollectionDiff, error) {
+	diff := CollectionDiff{Collection: collectionName}
+	domainUUID, err := uuid.FromString(DOMAIN)
+	if err != nil {
+		return diff, newRepositoryError(collectionName, PHASE_PREPARE, err)
+	}
+	var existing []models.Variation
+	if err := c.collection(db, collectionName).Find(nil).All(&existing); err != nil {
+		return diff, newRepositoryError(collectionName+c.CollectionSuffix, PHASE_READ, err)
+	}
+	existingByUUID := make(map[string]models.Variation)
+	for _, document := range existing {
+		existingByUUID[hex.EncodeToString(document.UUID)] = document
+	}
+	produced := make(map[string]struct{})
+	for _, card := range cards {
+		for _, variation := range c.newVariations(domainUUID, card) {
+			document, err := normalizeDocument(variation)
+			if err != nil {
+				return diff, newRepositoryError(collectionName, PHASE_PREPARE, err)
+			}
+			newDocument := document.(models.Variation)
+			key := hex.EncodeToString(newDocument.UUID)
+			produced[key] = struct{}{}
+			name := variationName(card.Name["en-US"], newDocument)
+			old, ok := existingByUUID[key]
+			if !ok {
+				diff.Added = append(diff.Added, name)
+				continue
+			}
+			diff.addChanges(key, name, old, newDocument)
+		}
+	}
+
+	var stale []models.Variation
+	for key, document := range existingByUUID {
+		if _, ok := produced[key]; !ok {
+			stale = append(stale, document)
+		}
+	}
+	if len(stale) > 0 {
+		cardNames, err := loadCardNames(c.collection(db, cardCollectionName))
+		if err != nil {
+			return diff, newRepositoryError(cardCollectionName+c.CollectionSuffix, PHASE_READ, err)
+		}
+		for _, document := range stale {
+			diff.Stale = append(diff.Stale, variationName(cardNames[document.Card_id], document))
+		}
+	}
+	diff.sort()
+	return diff, nil
+}
+
+func loadCardNames(collection *mgo.Collection) (map[bson.ObjectId]string, error) {
+	var cards []models.Card
+	if err := collection.Find(nil).Select(bson.M{"_id": 1, "name.en-US": 1}).All(&cards); err != nil {
+		return nil, err
+	}
+	names := make(map[bson.ObjectId]string)
+	for _, card := range cards {
+		names[card.ID] = card.Name["en-US"]
+	}
+	return names, nil
+}
+
+func variationName(cardName string, variation models.Variation) string {
+	return fmt.Sprintf("%s (%s)", cardName, variation.Availability)
+}
+
+func (d *CollectionDiff) addChanges(key, name string, old, new interface{}) {
+	var changes []FieldChange
+	diffValues("", reflect.ValueOf(old), reflect.ValueOf(new), &changes)
+	if len(changes) == 0 {
+		d.Unchanged++
+		return
+	}
+	d.Modified = append(d.Modified, DocumentChange{UUID: key, Name: name, Changes: changes})
+}
+
+func (d *CollectionDiff) sort() {
+	sort.Strings(d.Added)
+	sort.Strings(d.Stale)
+	sort.Slice(d.Modified, func(i, j int) bool {
+		return d.Modified[i].Name < d.Modified[j].Name
+	})
+}
+
+// normalizeDocument encodes and decodes a document so that it can be compared
+// to a document read from the database (nil and empty values, time precision...).
+func normalizeDocument(document interface{}) (interface{}, error) {
+	data, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	normalized := reflect.New(reflect.TypeOf(document))
+	if err := bson.Unmarshal(data, normalized.Interface()); err != nil {
+		return nil, err
+	}
+	return normalized.Elem().Interface(), nil
+}
+
+// diffValues appends the changes between two values of the same type. Structs and maps are
+// compared field by field, the name of the fields are joined with a dot.
+func diffValues(path string, old, new reflect.Value, changes *[]FieldChange) {
+	for old.Kind() == reflect.Ptr {
+		if old.IsNil() || new.IsNil() {
+			if old.IsNil() != new.IsNil() {
+				*changes = append(*changes, FieldChange{Field: path, Old: derefValue(old), New: derefValue(new)})
+			}
+			return
+		}
+		old, new = old.Elem(), new.Elem()
+	}
+	switch old.Kind() {
+	case reflect.Struct:
+		for i := 0; i < old.NumField(); i++ {
+			field := old.Type().Field(i)
+			if diffIgnoredFields[field.Name] {
+				continue
+			}
+			diffValues(joinPath(path, fieldName(field)), old.Field(i), new.Field(i), changes)
+		}
+	case reflect.Map:
+		keys := make(map[string]reflect.Value)
+		for _, key := range old.MapKeys() {
+			keys[fmt.Sprint(key.Interface())] = key
+		}
+		for _, key := range new.MapKeys() {
+			keys[fmt.Sprint(key.Interface())] = key
+		}
+		names := make([]string, 0, len(keys))
+		for name := range keys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			oldValue := old.MapIndex(keys[name])
+			newValue := new.MapIndex(keys[name])
+			if !oldValue.IsValid() || !newValue.IsValid() {
+				*changes = append(*changes, FieldChange{Field: joinPath(path, name), Old: mapValue(oldValue), New: mapValue(newValue)})
+				continue
+			}
+			diffValues(joinPath(path, name), oldValue, newValue, changes)
+		}
+	default:
+		if !reflect.DeepEqual(old.Interface(), new.Interface()) {
+			*changes = append(*changes, FieldChange{Field: path, Old: old.Interface(), New: new.Interface()})
+		}
+	}
+}
+
+func derefValue(value reflect.Value) interface{} {
+	if value.IsNil() {
+		return nil
+	}
+	return value.Elem().Interface()
+}
+
+func mapValue(value reflect.Value) interface{} {
+	if !value.IsValid() {
+		return nil
+	}
+	return value.Interface()
+}
+
+func joinPath(path, name string) string {
+	if len(path) == 0 {
+		return name
+	}
+	return path + "." + name
+}
+
+// fieldName returns the name of the field in the database.
+func fieldName(field reflect.StructField) string {
+	tag := field.Tag.Get("bson")
+	if len(tag) == 0 && !strings.Contains(string(field.Tag), ":") {
+		tag = string(field.Tag)
+	}
+	if name := strings.Split(tag, ",")[0]; len(name) > 0 && name != "-" {
+		return name
+	}
+	return strings.ToLower(field.Name)
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/mgo.v2"
+)
+
+// Phases of the operations on a collection, reported by RepositoryError.
+const (
+	PHASE_PREPARE  string = "prepare"
+	PHASE_INDEX    string = "index"
+	PHASE_LOOKUP   string = "lookup"
+	PHASE_READ     string = "read"
+	PHASE_WRITE    string = "write"
+	PHASE_DELETE   string = "delete"
+	PHASE_COPY     string = "copy"
+	PHASE_VALIDATE string = "validate"
+	PHASE_RENAME   string = "rename"
+)
+
+// RepositoryError is the error returned by ReposClient when an operation on a collection fails.
+type RepositoryError struct {
+	Collection string
+	Phase      string
+	Err        error
+}
+
+func (e *RepositoryError) Error() string {
+	cases := e.BulkErrors()
+	if len(cases) == 0 {
+		return fmt.Sprintf("%s (%s): %s", e.Collection, e.Phase, e.Err)
+	}
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "%s (%s): %d operation(s) of the bulk write failed", e.Collection, e.Phase, len(cases))
+	for _, bulkCase := range cases {
+		fmt.Fprintf(&buffer, "\n  operation %d: %s", bulkCase.Index, bulkCase.Err)
+	}
+	return buffer.String()
+}
+
+// BulkErrors returns the failed operations of a bulk write, nil if the error is not a bulk write error.
+func (e *RepositoryError) BulkErrors() []mgo.BulkErrorCase {
+	if bulkErr, ok := e.Err.(*mgo.BulkError); ok {
+		return bulkErr.Cases()
+	}
+	return nil
+}
+
+// newRepositoryError returns nil if err is nil.
+func newRepositoryError(collection, phase string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RepositoryError{Collection: collection, Phase: phase, Err: err}
+}
@@ -0,0 +1,180 @@
+package database
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	EXPORT_FORMAT_BSON string = "bson"
+	EXPORT_FORMAT_JSON string = "json"
+)
+
+// ExportCollection writes every document of the collection to w and returns the number of documents written.
+// With EXPORT_FORMAT_BSON, the documents are written one after the other like mongodump does.
+// With EXPORT_FORMAT_JSON, the documents are written in extended JSON, one document per line.
+func (c ReposClient) ExportCollection(collection *mgo.Collection, format string, w io.Writer) (int, error) {
+	if format != EXPORT_FORMAT_BSON && format != EXPORT_FORMAT_JSON {
+		return 0, fmt.Errorf("unknown export format: %s", format)
+	}
+	count := 0
+	iter := collection.Find(nil).Sort("_id").Iter()
+	var raw bson.Raw
+	for iter.Next(&raw) {
+		if format == EXPORT_FORMAT_BSON {
+			if _, err := w.Write(raw.Data); err != nil {
+				iter.Close()
+				return count, err
+			}
+		} else {
+			var document bson.D
+			if err := raw.Unmarshal(&document); err != nil {
+				iter.Close()
+				return count, err
+			}
+			line, err := MarshalExtendedJSON(document)
+			if err != nil {
+				iter.Close()
+				return count, err
+			}
+			if _, err := w.Write(append(line, '\n')); err != nil {
+				iter.Close()
+				return count, err
+			}
+		}
+		count++
+	}
+	return count, iter.Close()
+}
+
+// ExportMetadata writes the indexes of the collection in the format of the metadata files of mongodump.
+func (c ReposClient) ExportMetadata(collection *mgo.Collection, w io.Writer) error {
+	indexes, err := collection.Indexes()
+	// 26: NamespaceNotFound, the collection doesn't exist yet.
+	if queryErr, ok := err.(*mgo.QueryError); err != nil && (!ok || queryErr.Code != 26) {
+		return err
+	}
+	ns := collection.FullName
+	metadata := bson.D{{Name: "options", Value: bson.D{}}}
+	var indexDocuments []interface{}
+	for _, index := range indexes {
+		key := bson.D{}
+		for _, field := range index.Key {
+			if len(field) > 0 && field[0] == '-' {
+				key = append(key, bson.DocElem{Name: field[1:], Value: -1})
+			} else {
+				key = append(key, bson.DocElem{Name: field, Value: 1})
+			}
+		}
+		document := bson.D{
+			{Name: "v", Value: 1},
+			{Name: "key", Value: key},
+			{Name: "name", Value: index.Name},
+			{Name: "ns", Value: ns},
+		}
+		if index.Unique {
+			document = append(document, bson.DocElem{Name: "unique", Value: true})
+		}
+		if index.Background {
+			document = append(document, bson.DocElem{Name: "background", Value: true})
+		}
+		if index.Sparse {
+			document = append(document, bson.DocElem{Name: "sparse", Value: true})
+		}
+		indexDocuments = append(indexDocuments, document)
+	}
+	metadata = append(metadata, bson.DocElem{Name: "indexes", Value: indexDocuments})
+	data, err := MarshalExtendedJSON(metadata)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// MarshalExtendedJSON returns the MongoDB extended JSON (relaxed mode) encoding of a document.
+// The order of the fields of bson.D documents is preserved.
+func MarshalExtendedJSON(value interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := writeExtendedJSON(&buffer, value); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func writeExtendedJSON(buffer *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case bson.D:
+		buffer.WriteByte('{')
+		for i, element := range v {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := writeJSONString(buffer, element.Name); err != nil {
+				return err
+			}
+			buffer.WriteByte(':')
+			if err := writeExtendedJSON(buffer, element.Value); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+	case bson.M:
+		document := bson.D{}
+		for name, element := range v {
+			document = append(document, bson.DocElem{Name: name, Value: element})
+		}
+		return writeExtendedJSON(buffer, document)
+	case []interface{}:
+		buffer.WriteByte('[')
+		for i, element := range v {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := writeExtendedJSON(buffer, element); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+	case bson.ObjectId:
+		return writeExtendedJSON(buffer, bson.D{{Name: "$oid", Value: v.Hex()}})
+	case time.Time:
+		return writeExtendedJSON(buffer, bson.D{{Name: "$date", Value: v.UTC().Format("2006-01-02T15:04:05.000Z07:00")}})
+	case []byte:
+		return writeExtendedJSON(buffer, bson.Binary{Kind: 0x00, Data: v})
+	case bson.Binary:
+		binary := bson.D{
+			{Name: "base64", Value: base64.StdEncoding.EncodeToString(v.Data)},
+			{Name: "subType", Value: fmt.Sprintf("%02x", v.Kind)},
+		}
+		return writeExtendedJSON(buffer, bson.D{{Name: "$binary", Value: binary}})
+	case int64:
+		return writeExtendedJSON(buffer, bson.D{{Name: "$numberLong", Value: strconv.FormatInt(v, 10)}})
+	case bson.RegEx:
+		regex := bson.D{{Name: "pattern", Value: v.Pattern}, {Name: "options", Value: v.Options}}
+		return writeExtendedJSON(buffer, bson.D{{Name: "$regularExpression", Value: regex}})
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buffer.Write(data)
+	}
+	return nil
+}
+
+func writeJSONString(buffer *bytes.Buffer, s string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	buffer.Write(data)
+	return nil
+}
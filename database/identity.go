@@ -0,0 +1,230 @@
+package database
+
+import (
+	"fmt"
+	"github.com/GwentAPI/manipulator/models"
+	"github.com/satori/go.uuid"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+	"strings"
+	"time"
+)
+
+// Strategies used to derive the UUID of the cards and variations.
+const (
+	// The English name of the card, and its availability for the variations.
+	UUID_STRATEGY_NAME string = "name"
+	// The IngameId of the card and the VariationId of the variations, which survive a renaming.
+	UUID_STRATEGY_INGAMEID string = "ingameid"
+)
+
+// Collection recording the previous UUID of the documents re-keyed by a migration.
+const ALIAS_COLLECTION string = "card_aliases"
+
+// UUIDChange is the change of UUID of a document.
+type UUIDChange struct {
+	Name string
+	Old  []byte
+	New  []byte
+}
+
+func (c ReposClient) cardUUID(domainUUID uuid.UUID, card models.GwentCard) []byte {
+	if c.UUIDStrategy == UUID_STRATEGY_INGAMEID {
+		return uuid.NewV5(domainUUID, "ingameid:"+card.IngameId).Bytes()
+	}
+	return uuid.NewV5(domainUUID, card.Name["en-US"]).Bytes()
+}
+
+func (c ReposClient) variationUUID(domainUUID uuid.UUID, card models.GwentCard, variation models.GwentVariation) []byte {
+	if c.UUIDStrategy == UUID_STRATEGY_INGAMEID {
+		return uuid.NewV5(domainUUID, "variation:"+variation.VariationId).Bytes()
+	}
+	// UUID : name + availability
+	return uuid.NewV5(domainUUID, card.Name["en-US"]+variation.Availability).Bytes()
+}
+
+// CheckUUIDStrategy verifies that the strategy can derive a distinct UUID for every card and variation.
+// With the name strategy, colliding variations are only reported: they have always been merged.
+func (c ReposClient) CheckUUIDStrategy(cards map[string]models.GwentCard) error {
+	if len(c.UUIDStrategy) > 0 && c.UUIDStrategy != UUID_STRATEGY_NAME && c.UUIDStrategy != UUID_STRATEGY_INGAMEID {
+		return fmt.Errorf("unknown UUID strategy: %s", c.UUIDStrategy)
+	}
+	domainUUID, err := uuid.FromString(DOMAIN)
+	if err != nil {
+		return err
+	}
+	var problems []string
+	cardUUIDs := make(map[string]string)
+	variationUUIDs := make(map[string]string)
+	for _, card := range cards {
+		name := card.Name["en-US"]
+		if c.UUIDStrategy == UUID_STRATEGY_INGAMEID && len(card.IngameId) == 0 {
+			problems = append(problems, fmt.Sprintf("%s: no IngameId", name))
+		} else {
+			key := string(c.cardUUID(domainUUID, card))
+			if other, ok := cardUUIDs[key]; ok {
+				problems = append(problems, fmt.Sprintf("%s: same UUID as %s", name, other))
+			}
+			cardUUIDs[key] = name
+		}
+		for _, key := range card.VariationKeys() {
+			variation := card.Variations[key]
+			if c.UUIDStrategy == UUID_STRATEGY_INGAMEID && len(variation.VariationId) == 0 {
+				problems = append(problems, fmt.Sprintf("%s: no VariationId for variation %s", name, key))
+				continue
+			}
+			key := string(c.variationUUID(domainUUID, card, variation))
+			if other, ok := variationUUIDs[key]; ok {
+				problem := fmt.Sprintf("%s: variation %s has the same UUID as a variation of %s", name, variation.Availability, other)
+				if c.UUIDStrategy != UUID_STRATEGY_INGAMEID {
+					log.Println("WARNING:", problem)
+					continue
+				}
+				problems = append(problems, problem)
+			}
+			variationUUIDs[key] = name
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("the %s UUID strategy can't be used:\n  %s", c.UUIDStrategy, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// CardUUIDChanges returns the change of UUID of every card from the strategy of c to the strategy of to.
+func (c ReposClient) CardUUIDChanges(to ReposClient, cards map[string]models.GwentCard) ([]UUIDChange, error) {
+	domainUUID, err := uuid.FromString(DOMAIN)
+	if err != nil {
+		return nil, err
+	}
+	var changes []UUIDChange
+	for _, card := range cards {
+		changes = append(changes, UUIDChange{
+			Name: card.Name["en-US"],
+			Old:  c.cardUUID(domainUUID, card),
+			New:  to.cardUUID(domainUUID, card),
+		})
+	}
+	return changes, nil
+}
+
+// VariationUUIDChanges returns the change of UUID of every variation from the strategy of c to the strategy of to.
+func (c ReposClient) VariationUUIDChanges(to ReposClient, cards map[string]models.GwentCard) ([]UUIDChange, error) {
+	domainUUID, err := uuid.FromString(DOMAIN)
+	if err != nil {
+		return nil, err
+	}
+	var changes []UUIDChange
+	for _, card := range cards {
+		for _, key := range card.VariationKeys() {
+			variation := card.Variations[key]
+			changes = append(changes, UUIDChange{
+				Name: fmt.Sprintf("%s (%s)", card.Name["en-US"], variation.Availability),
+				Old:  c.variationUUID(domainUUID, card, variation),
+				New:  to.variationUUID(domainUUID, card, variation),
+			})
+		}
+	}
+	return changes, nil
+}
+
+// MigrateUUIDs replaces the old UUID of the documents of the collection by the new one
+// and returns the number of documents re-keyed. The documents keep their _id.
+func (c ReposClient) MigrateUUIDs(db *mgo.Database, collectionName string, changes []UUIDChange) (int, error) {
+	collection := c.collection(db, collectionName)
+	if len(changes) == 0 {
+		return 0, nil
+	}
+	bulk := collection.Bulk()
+	bulk.Unordered()
+	for _, change := range changes {
+		bulk.Update(bson.M{"uuid": change.Old}, bson.M{"$set": bson.M{"uuid": change.New}})
+	}
+	result, err := bulk.Run()
+	if err != nil {
+		return 0, newRepositoryError(collection.Name, PHASE_WRITE, err)
+	}
+	return result.Matched, nil
+}
+
+// InsertAliases records the changes of UUID of a collection in ALIAS_COLLECTION.
+func (c ReposClient) InsertAliases(db *mgo.Database, collectionName string, changes []UUIDChange) error {
+	var aliases []models.Alias
+	for _, change := range changes {
+		if string(change.Old) == string(change.New) {
+			continue
+		}
+		aliases = append(aliases, models.Alias{
+			Collection: collectionName,
+			Name:       change.Name,
+			OldUUID:    change.Old,
+			NewUUID:    change.New,
+		})
+	}
+	return insertAliases(db, aliases)
+}
+
+// insertAliases upserts the aliases in ALIAS_COLLECTION. It is never written to the staging
+// collections: an atomic update records its aliases once the collections are swapped.
+// An alias is identified by its old UUID and old art slug: with the ingameid strategy,
+// the UUID of a renamed card doesn't change.
+func insertAliases(db *mgo.Database, aliases []models.Alias) error {
+	collection := db.C(ALIAS_COLLECTION)
+	index := mgo.Index{
+		Key:        []string{"old_uuid", "old_art_slug"},
+		Unique:     true,
+		Background: true,
+		Name:       "old_uuid_old_art_slug",
+	}
+	if err := collection.EnsureIndex(index); err != nil {
+		return newRepositoryError(collection.Name, PHASE_INDEX, err)
+	}
+	aliases, err := newAliases(collection, aliases)
+	if err != nil {
+		return err
+	}
+	if len(aliases) == 0 {
+		return nil
+	}
+	bulk := collection.Bulk()
+	bulk.Unordered()
+	for _, alias := range aliases {
+		alias.Last_Modified = time.Now().UTC()
+		bulk.Upsert(bson.M{"old_uuid": alias.OldUUID, "old_art_slug": alias.OldArtSlug}, alias)
+	}
+	_, err = bulk.Run()
+	return newRepositoryError(collection.Name, PHASE_WRITE, err)
+}
+
+// newAliases returns the aliases which aren't already recorded with the same target,
+// so that running an update or a migration again doesn't rewrite them.
+func newAliases(collection *mgo.Collection, aliases []models.Alias) ([]models.Alias, error) {
+	if len(aliases) == 0 {
+		return nil, nil
+	}
+	var oldUUIDs [][]byte
+	for _, alias := range aliases {
+		oldUUIDs = append(oldUUIDs, alias.OldUUID)
+	}
+	var recorded []models.Alias
+	if err := collection.Find(bson.M{"old_uuid": bson.M{"$in": oldUUIDs}}).All(&recorded); err != nil {
+		return nil, newRepositoryError(collection.Name, PHASE_READ, err)
+	}
+	key := func(alias models.Alias) string {
+		return string(alias.OldUUID) + "\x00" + alias.OldArtSlug + "\x00" + string(alias.NewUUID) + "\x00" + alias.NewArtSlug
+	}
+	known := make(map[string]struct{})
+	for _, alias := range recorded {
+		known[key(alias)] = struct{}{}
+	}
+	var result []models.Alias
+	for _, alias := range aliases {
+		if _, ok := known[key(alias)]; ok {
+			continue
+		}
+		known[key(alias)] = struct{}{}
+		result = append(result, alias)
+	}
+	return result, nil
+}
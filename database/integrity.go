@@ -0,0 +1,99 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/GwentAPI/manipulator/models"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+	"sort"
+)
+
+const PHASE_REFERENCES string = "references"
+
+// DanglingReference is a reference of a card to a document that doesn't exist.
+type DanglingReference struct {
+	Card  string
+	Field string
+	Value string
+}
+
+func (r DanglingReference) String() string {
+	value := r.Value
+	if len(value) == 0 {
+		value = "(empty)"
+	}
+	return fmt.Sprintf("%s: %s %q not found", r.Card, r.Field, value)
+}
+
+// DanglingReferencesError lists the dangling references found before writing a collection.
+type DanglingReferencesError struct {
+	References []DanglingReference
+}
+
+func (e *DanglingReferencesError) Error() string {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "%d dangling reference(s)", len(e.References))
+	for _, reference := range e.References {
+		fmt.Fprintf(&buffer, "\n  %s", reference)
+	}
+	return buffer.String()
+}
+
+// FindDanglingReferences returns the references of the cards that are not in the generic collections,
+// given by name, before anything is written. Empty values are always dangling.
+func FindDanglingReferences(cards map[string]models.GwentCard, collections map[string]map[string]struct{}) []DanglingReference {
+	var dangling []DanglingReference
+	check := func(card, field, collection, value string) {
+		if _, ok := collections[collection][value]; !ok || len(value) == 0 {
+			dangling = append(dangling, DanglingReference{Card: card, Field: field, Value: value})
+		}
+	}
+	for _, card := range cards {
+		name := card.Name["en-US"]
+		check(name, "faction", "factions", card.Faction)
+		check(name, "group", "groups", card.Group)
+		for _, category := range card.Categories {
+			check(name, "category", "categories", category)
+		}
+		for _, key := range card.VariationKeys() {
+			check(name, "rarity", "rarities", card.Variations[key].Rarity)
+		}
+	}
+	sort.Slice(dangling, func(i, j int) bool {
+		if dangling[i].Card != dangling[j].Card {
+			return dangling[i].Card < dangling[j].Card
+		}
+		return dangling[i].Field < dangling[j].Field
+	})
+	return dangling
+}
+
+// referenceChecker collects the references that can't be resolved while building the documents of a collection.
+type referenceChecker struct {
+	dangling []DanglingReference
+}
+
+// resolve returns the _id of value in ids, recording a dangling reference if it is missing.
+func (r *referenceChecker) resolve(ids map[string]bson.ObjectId, card, field, value string) bson.ObjectId {
+	id, ok := ids[value]
+	if !ok || len(value) == 0 {
+		r.dangling = append(r.dangling, DanglingReference{Card: card, Field: field, Value: value})
+	}
+	return id
+}
+
+// check returns an error listing the dangling references, unless the client allows them:
+// they are then only logged and the references are left empty.
+func (r *referenceChecker) check(c ReposClient, collectionName string) error {
+	if len(r.dangling) == 0 {
+		return nil
+	}
+	if !c.AllowDangling {
+		return newRepositoryError(collectionName, PHASE_REFERENCES, &DanglingReferencesError{References: r.dangling})
+	}
+	for _, reference := range r.dangling {
+		log.Printf("WARNING: dangling reference in %s, %s", collectionName, reference)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"github.com/GwentAPI/manipulator/models"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// PruneGenericCollection deletes the documents of a generic collection that are not in names.
+// It returns the names of the deleted documents.
+func (c ReposClient) PruneGenericCollection(db *mgo.Database, collectionName string, names map[string]struct{}) ([]string, error) {
+	keep, err := GenericUUIDs(names)
+	if err != nil {
+		return nil, newRepositoryError(collectionName, PHASE_PREPARE, err)
+	}
+	var stale []models.GenericCollection
+	selector := staleSelector(keep)
+	if err := c.collection(db, collectionName).Find(selector).Select(bson.M{"name": 1}).All(&stale); err != nil {
+		return nil, newRepositoryError(collectionName+c.CollectionSuffix, PHASE_READ, err)
+	}
+	var removed []string
+	for _, document := range stale {
+		removed = append(removed, document.Name)
+	}
+	return removed, removeAll(c.collection(db, collectionName), selector, len(stale))
+}
+
+// PruneCards deletes the cards that are not produced by cards.
+// It returns the names of the deleted cards.
+func (c ReposClient) PruneCards(db *mgo.Database, collectionName string, cards map[string]models.GwentCard) ([]string, error) {
+	keep, err := c.CardUUIDs(cards)
+	if err != nil {
+		return nil, newRepositoryError(collectionName, PHASE_PREPARE, err)
+	}
+	var stale []models.Card
+	selector := staleSelector(keep)
+	if err := c.collection(db, collectionName).Find(selector).Select(bson.M{"name.en-US": 1}).All(&stale); err != nil {
+		return nil, newRepositoryError(collectionName+c.CollectionSuffix, PHASE_READ, err)
+	}
+	var removed []string
+	for _, document := range stale {
+		removed = append(removed, document.Name["en-US"])
+	}
+	return removed, removeAll(c.collection(db, collectionName), selector, len(stale))
+}
+
+// PruneVariations deletes the variations that are not produced by cards.
+// It must be called before PruneCards: cardCollectionName is used to name the deleted variations.
+func (c ReposClient) PruneVariations(db *mgo.Database, collectionName string, cardCollectionName string, cards map[string]models.GwentCard) ([]string, error) {
+	keep, err := c.VariationUUIDs(cards)
+	if err != nil {
+		return nil, newRepositoryError(collectionName, PHASE_PREPARE, err)
+	}
+	var stale []models.Variation
+	selector := staleSelector(keep)
+	if err := c.collection(db, collectionName).Find(selector).Select(bson.M{"card_id": 1, "availability": 1}).All(&stale); err != nil {
+		return nil, newRepositoryError(collectionName+c.CollectionSuffix, PHASE_READ, err)
+	}
+	if len(stale) == 0 {
+		return nil, nil
+	}
+	cardNames, err := loadCardNames(c.collection(db, cardCollectionName))
+	if err != nil {
+		return nil, newRepositoryError(cardCollectionName+c.CollectionSuffix, PHASE_READ, err)
+	}
+	var removed []string
+	for _, document := range stale {
+		removed = append(removed, variationName(cardNames[document.Card_id], document))
+	}
+	return removed, removeAll(c.collection(db, collectionName), selector, len(stale))
+}
+
+func staleSelector(keep [][]byte) bson.M {
+	return bson.M{"uuid": bson.M{"$nin": keep}}
+}
+
+func removeAll(collection *mgo.Collection, selector bson.M, count int) error {
+	if count == 0 {
+		return nil
+	}
+	_, err := collection.RemoveAll(selector)
+	return newRepositoryError(collection.Name, PHASE_DELETE, err)
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"github.com/GwentAPI/manipulator/common"
+	"github.com/GwentAPI/manipulator/models"
+	"github.com/satori/go.uuid"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+	"sort"
+)
+
+// CardRename is a card of the input file whose IngameId is stored with another English name.
+type CardRename struct {
+	IngameId   string
+	OldName    string
+	NewName    string
+	OldUUID    []byte
+	NewUUID    []byte
+	OldArtSlug string
+	NewArtSlug string
+	// The variations whose UUID changed with the name.
+	Variations []UUIDChange
+}
+
+// DetectRenames compares the cards to the documents of the card collection by IngameId.
+// Only the documents written since the IngameId is stored can be matched. A card taking the name
+// or the UUID of another document is not a rename and is skipped with a warning.
+func (c ReposClient) DetectRenames(db *mgo.Database, collectionName string, cards map[string]models.GwentCard) ([]CardRename, error) {
+	collection := c.collection(db, collectionName)
+	domainUUID, err := uuid.FromString(DOMAIN)
+	if err != nil {
+		return nil, newRepositoryError(collection.Name, PHASE_PREPARE, err)
+	}
+	var existing []models.Card
+	if err := collection.Find(nil).Select(bson.M{"ingameId": 1, "name.en-US": 1, "uuid": 1}).All(&existing); err != nil {
+		return nil, newRepositoryError(collection.Name, PHASE_READ, err)
+	}
+	existingByIngameId := make(map[string]models.Card)
+	// Documents by UUID and by English name, to find the documents a rename would collide with.
+	existingByUUID := make(map[string]bson.ObjectId)
+	existingByName := make(map[string]bson.ObjectId)
+	for _, document := range existing {
+		if len(document.IngameId) > 0 {
+			existingByIngameId[document.IngameId] = document
+		}
+		existingByUUID[string(document.UUID)] = document.ID
+		existingByName[document.Name["en-US"]] = document.ID
+	}
+
+	var renames []CardRename
+	for _, card := range cards {
+		if len(card.IngameId) == 0 {
+			continue
+		}
+		old, ok := existingByIngameId[card.IngameId]
+		if !ok || old.Name["en-US"] == card.Name["en-US"] {
+			continue
+		}
+		rename := CardRename{
+			IngameId:   card.IngameId,
+			OldName:    old.Name["en-US"],
+			NewName:    card.Name["en-US"],
+			OldUUID:    old.UUID,
+			NewUUID:    c.cardUUID(domainUUID, card),
+			OldArtSlug: common.GetArtUrl(old.Name["en-US"]),
+			NewArtSlug: common.GetArtUrl(card.Name["en-US"]),
+		}
+		// Another document already has the new name or UUID: the card isn't simply renamed.
+		if id, ok := existingByUUID[string(rename.NewUUID)]; ok && id != old.ID {
+			log.Printf("Card %s is not recorded as renamed from %s: another card already has its UUID.", rename.NewName, rename.OldName)
+			continue
+		}
+		if id, ok := existingByName[rename.NewName]; ok && id != old.ID {
+			log.Printf("Card %s is not recorded as renamed from %s: another card already has its name.", rename.NewName, rename.OldName)
+			continue
+		}
+		// The card as it was defined before the rename, to compute the old UUID of its variations.
+		oldCard := card
+		oldCard.Name = map[string]string{"en-US": rename.OldName}
+		for _, key := range card.VariationKeys() {
+			variation := card.Variations[key]
+			change := UUIDChange{
+				Name: variationName(rename.NewName, models.Variation{Availability: variation.Availability}),
+				Old:  c.variationUUID(domainUUID, oldCard, variation),
+				New:  c.variationUUID(domainUUID, card, variation),
+			}
+			if string(change.Old) != string(change.New) {
+				rename.Variations = append(rename.Variations, change)
+			}
+		}
+		renames = append(renames, rename)
+	}
+	sort.Slice(renames, func(i, j int) bool {
+		return renames[i].NewName < renames[j].NewName
+	})
+	return renames, nil
+}
+
+// InsertRenameAliases records the renamed cards, and their variations, in ALIAS_COLLECTION.
+func (c ReposClient) InsertRenameAliases(db *mgo.Database, cardCollectionName string, variationCollectionName string, renames []CardRename) error {
+	var aliases []models.Alias
+	for _, rename := range renames {
+		aliases = append(aliases, models.Alias{
+			Collection: cardCollectionName,
+			Name:       rename.NewName,
+			OldName:    rename.OldName,
+			OldUUID:    rename.OldUUID,
+			NewUUID:    rename.NewUUID,
+			OldArtSlug: rename.OldArtSlug,
+			NewArtSlug: rename.NewArtSlug,
+		})
+		for _, variation := range rename.Variations {
+			aliases = append(aliases, models.Alias{
+				Collection: variationCollectionName,
+				Name:       variation.Name,
+				OldName:    rename.OldName,
+				OldUUID:    variation.Old,
+				NewUUID:    variation.New,
+				OldArtSlug: rename.OldArtSlug,
+				NewArtSlug: rename.NewArtSlug,
+			})
+		}
+	}
+	return insertAliases(db, aliases)
+}
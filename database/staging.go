@@ -0,0 +1,250 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+)
+
+const (
+	STAGING_SUFFIX  string = "_next"
+	PREVIOUS_SUFFIX string = "_prev"
+	rollbackSuffix  string = "_rollback"
+)
+
+func (c ReposClient) collection(db *mgo.Database, name string) *mgo.Collection {
+	return db.C(name + c.CollectionSuffix)
+}
+
+// PrepareStaging replaces the staging collections by a copy of the live collections,
+// so that the documents keep their _id and the documents that are not updated are kept.
+func (c ReposClient) PrepareStaging(db *mgo.Database, names []string) error {
+	existing, err := collectionSet(db)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		staging := name + STAGING_SUFFIX
+		if existing[staging] {
+			if err := db.C(staging).DropCollection(); err != nil {
+				return newRepositoryError(staging, PHASE_PREPARE, err)
+			}
+		}
+		if !existing[name] {
+			continue
+		}
+		if err := copyCollection(db, name, staging); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckCount returns an error if the documents identified by uuids are not all in the collection.
+func (c ReposClient) CheckCount(db *mgo.Database, collectionName string, uuids [][]byte) error {
+	collection := c.collection(db, collectionName)
+	count, err := collection.Find(bson.M{"uuid": bson.M{"$in": uuids}}).Count()
+	if err != nil {
+		return newRepositoryError(collection.Name, PHASE_READ, err)
+	}
+	if count != len(uuids) {
+		err := fmt.Errorf("%d of the %d expected documents found", count, len(uuids))
+		return newRepositoryError(collection.Name, PHASE_VALIDATE, err)
+	}
+	return nil
+}
+
+// SwapStaging replaces the live collections by the staging collections. The live collections
+// are first copied with the PREVIOUS_SUFFIX for RollbackStaging, replacing the previous generation,
+// so they stay available until each staging collection atomically replaces its live collection.
+// If a replacement fails, the collections already swapped are restored from their copy.
+func (c ReposClient) SwapStaging(db *mgo.Database, names []string) error {
+	existing, err := collectionSet(db)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if existing[name] && !existing[name+STAGING_SUFFIX] {
+			return newRepositoryError(name+STAGING_SUFFIX, PHASE_RENAME, errors.New("the staging collection doesn't exist"))
+		}
+	}
+	// A collection which doesn't exist yet has an empty previous generation.
+	for _, name := range names {
+		var err error
+		if existing[name] {
+			err = copyCollection(db, name, name+PREVIOUS_SUFFIX)
+		} else {
+			err = createEmptyCollection(db, name+PREVIOUS_SUFFIX, existing[name+PREVIOUS_SUFFIX])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	var swapped []string
+	for _, name := range names {
+		// Nothing was written to the collection.
+		if !existing[name+STAGING_SUFFIX] {
+			continue
+		}
+		if err := renameCollection(db, name+STAGING_SUFFIX, name); err != nil {
+			restoreSwapped(db, swapped, existing)
+			return err
+		}
+		swapped = append(swapped, name)
+	}
+	return nil
+}
+
+// restoreSwapped puts back the live collections replaced by SwapStaging before it failed.
+// A collection which didn't exist before the swap is dropped.
+func restoreSwapped(db *mgo.Database, names []string, existing map[string]bool) {
+	for _, name := range names {
+		var err error
+		if existing[name] {
+			err = copyCollection(db, name+PREVIOUS_SUFFIX, name)
+		} else {
+			err = db.C(name).DropCollection()
+		}
+		if err != nil {
+			log.Printf("Error while restoring the collection %s, it must be restored with rollback: %s", name, err)
+		} else {
+			log.Printf("Collection %s restored", name)
+		}
+	}
+}
+
+// RollbackStaging swaps the live collections with the previous generation,
+// a second rollback restores the collections replaced by the first one.
+// A collection without a previous generation is left untouched.
+func (c ReposClient) RollbackStaging(db *mgo.Database, names []string) error {
+	existing, err := collectionSet(db)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, name := range names {
+		found = found || existing[name+PREVIOUS_SUFFIX]
+	}
+	if !found {
+		return newRepositoryError(db.Name, PHASE_RENAME, errors.New("no previous generation"))
+	}
+	for _, name := range names {
+		// The collection was added after the previous generation was kept.
+		if !existing[name+PREVIOUS_SUFFIX] {
+			if existing[name] {
+				log.Printf("The collection %s doesn't have a previous generation, it is left untouched.", name)
+			}
+			continue
+		}
+		// The live collection is copied rather than renamed, so it never goes missing.
+		if existing[name] {
+			if err := copyCollection(db, name, name+rollbackSuffix); err != nil {
+				return err
+			}
+		}
+		if err := renameCollection(db, name+PREVIOUS_SUFFIX, name); err != nil {
+			return err
+		}
+		if existing[name] {
+			if err := renameCollection(db, name+rollbackSuffix, name+PREVIOUS_SUFFIX); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// copyCollection replaces the target collection by a copy of the source collection and its indexes,
+// which $out doesn't copy.
+func copyCollection(db *mgo.Database, from, to string) error {
+	command := bson.D{
+		{Name: "aggregate", Value: from},
+		{Name: "pipeline", Value: []bson.M{{"$out": to}}},
+		{Name: "cursor", Value: bson.M{}},
+	}
+	if err := db.Run(command, nil); err != nil {
+		return newRepositoryError(to, PHASE_COPY, err)
+	}
+	return copyIndexes(db, from, to)
+}
+
+// copyIndexes creates on the target collection the indexes of the source collection, with the
+// same specification. The _id index is created with the collection.
+func copyIndexes(db *mgo.Database, from, to string) error {
+	var result struct {
+		Cursor struct {
+			FirstBatch []bson.D `bson:"firstBatch"`
+		}
+	}
+	if err := db.Run(bson.D{{Name: "listIndexes", Value: from}}, &result); err != nil {
+		return newRepositoryError(from, PHASE_INDEX, err)
+	}
+	var indexes []bson.D
+	for _, spec := range result.Cursor.FirstBatch {
+		var index bson.D
+		for _, field := range spec {
+			// The namespace is the one of the source collection, the version is chosen by the server.
+			if field.Name == "ns" || field.Name == "v" {
+				continue
+			}
+			if field.Name == "name" && field.Value == "_id_" {
+				index = nil
+				break
+			}
+			index = append(index, field)
+		}
+		if index != nil {
+			indexes = append(indexes, index)
+		}
+	}
+	if len(indexes) == 0 {
+		return nil
+	}
+	command := bson.D{
+		{Name: "createIndexes", Value: to},
+		{Name: "indexes", Value: indexes},
+	}
+	if err := db.Run(command, nil); err != nil {
+		return newRepositoryError(to, PHASE_INDEX, err)
+	}
+	return nil
+}
+
+// createEmptyCollection replaces a collection by an empty one.
+func createEmptyCollection(db *mgo.Database, name string, exists bool) error {
+	if exists {
+		if err := db.C(name).DropCollection(); err != nil {
+			return newRepositoryError(name, PHASE_PREPARE, err)
+		}
+	}
+	if err := db.Run(bson.D{{Name: "create", Value: name}}, nil); err != nil {
+		return newRepositoryError(name, PHASE_PREPARE, err)
+	}
+	return nil
+}
+
+func renameCollection(db *mgo.Database, from, to string) error {
+	command := bson.D{
+		{Name: "renameCollection", Value: db.Name + "." + from},
+		{Name: "to", Value: db.Name + "." + to},
+		{Name: "dropTarget", Value: true},
+	}
+	if err := db.Session.DB("admin").Run(command, nil); err != nil {
+		return newRepositoryError(from, PHASE_RENAME, fmt.Errorf("renaming to %s: %s", to, err))
+	}
+	return nil
+}
+
+func collectionSet(db *mgo.Database) (map[string]bool, error) {
+	names, err := db.CollectionNames()
+	if err != nil {
+		return nil, newRepositoryError(db.Name, PHASE_READ, err)
+	}
+	set := make(map[string]bool)
+	for _, name := range names {
+		set[name] = true
+	}
+	return set, nil
+}
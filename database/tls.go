@@ -0,0 +1,127 @@
+package database
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+const MECHANISM_X509 string = "MONGODB-X509"
+
+// TLSConfig returns the TLS configuration used to connect to mongoDB.
+// TLSCAFile replaces the CA of the system, TLSCertificateKeyFile is a PEM file
+// containing both the client certificate and its private key.
+func (s MongoConnectionSettings) TLSConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         s.TLSServerName,
+		InsecureSkipVerify: s.TLSInsecure,
+	}
+	if len(s.TLSCAFile) > 0 {
+		pem, err := ioutil.ReadFile(s.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the CA file: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in the CA file %s", s.TLSCAFile)
+		}
+		config.RootCAs = pool
+	}
+	if len(s.TLSCertificateKeyFile) > 0 {
+		certificate, err := loadClientCertificate(s.TLSCertificateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
+
+func loadClientCertificate(path string) (tls.Certificate, error) {
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("error reading the certificate key file: %s", err)
+	}
+	certificate, err := tls.X509KeyPair(pem, pem)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("invalid certificate key file %s: %s", path, err)
+	}
+	return certificate, nil
+}
+
+// ClientCertificateSubject returns the subject of the client certificate in the RFC 2253 format,
+// which is the username of the MONGODB-X509 authentication mechanism.
+func (s MongoConnectionSettings) ClientCertificateSubject() (string, error) {
+	if len(s.TLSCertificateKeyFile) == 0 {
+		return "", errors.New("no client certificate")
+	}
+	certificate, err := loadClientCertificate(s.TLSCertificateKeyFile)
+	if err != nil {
+		return "", err
+	}
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		return "", err
+	}
+	return formatDistinguishedName(leaf.Subject.ToRDNSequence()), nil
+}
+
+var attributeTypeNames = map[string]string{
+	"2.5.4.3":                    "CN",
+	"2.5.4.5":                    "SERIALNUMBER",
+	"2.5.4.6":                    "C",
+	"2.5.4.7":                    "L",
+	"2.5.4.8":                    "ST",
+	"2.5.4.9":                    "STREET",
+	"2.5.4.10":                   "O",
+	"2.5.4.11":                   "OU",
+	"2.5.4.17":                   "POSTALCODE",
+	"0.9.2342.19200300.100.1.1":  "UID",
+	"0.9.2342.19200300.100.1.25": "DC",
+}
+
+// formatDistinguishedName formats a distinguished name as described by RFC 2253:
+// the last relative distinguished name comes first.
+func formatDistinguishedName(sequence pkix.RDNSequence) string {
+	var parts []string
+	for i := len(sequence) - 1; i >= 0; i-- {
+		var attributes []string
+		for _, attribute := range sequence[i] {
+			name, ok := attributeTypeNames[attribute.Type.String()]
+			if !ok {
+				name = attribute.Type.String()
+			}
+			attributes = append(attributes, name+"="+escapeAttributeValue(attribute.Value))
+		}
+		parts = append(parts, strings.Join(attributes, "+"))
+	}
+	return strings.Join(parts, ",")
+}
+
+func escapeAttributeValue(value interface{}) string {
+	s, ok := value.(string)
+	if !ok {
+		data, err := asn1.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return fmt.Sprintf("#%x", data)
+	}
+	var escaped bytes.Buffer
+	for i, r := range s {
+		switch {
+		case strings.ContainsRune(",+\"\\<>;", r),
+			i == 0 && (r == ' ' || r == '#'),
+			i == len(s)-1 && r == ' ':
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const URI_PREFIX string = "mongodb://"
+
+// rejectedOption is a standard option changing the security of the connection which isn't supported.
+// Ignoring it would connect with a different security than the one asked for.
+type rejectedOption struct {
+	reason string
+	// Value of the option keeping the default behaviour, accepted. Empty if every value is rejected.
+	harmless string
+}
+
+var rejectedOptions = map[string]rejectedOption{
+	"authmechanismproperties":              {reason: "only the authentication mechanisms without properties are supported"},
+	"gssapiservicename":                    {reason: "GSSAPI authentication is not supported"},
+	"sslallowinvalidhostnames":             {reason: "the verification of the server hostname can't be disabled", harmless: "false"},
+	"tlsallowinvalidhostnames":             {reason: "the verification of the server hostname can't be disabled", harmless: "false"},
+	"tlscertificatekeyfilepassword":        {reason: "encrypted client keys are not supported"},
+	"tlsdisablecertificaterevocationcheck": {reason: "the revocation check can't be configured", harmless: "false"},
+	"tlsdisableocspendpointcheck":          {reason: "the revocation check can't be configured", harmless: "false"},
+}
+
+// ParseMongoURI parses a standard connection string:
+// mongodb://[username:password@]host1[:port1][,host2[:port2],...][/database][?options]
+//
+// The supported options are authSource, authMechanism, replicaSet, readPreference,
+// ssl/tls, tlsCAFile, tlsCertificateKeyFile, tlsInsecure/tlsAllowInvalidCertificates and connectTimeoutMS.
+func ParseMongoURI(uri string) (MongoConnectionSettings, error) {
+	settings := MongoConnectionSettings{}
+	if strings.HasPrefix(uri, "mongodb+srv://") {
+		return settings, errors.New("mongodb+srv:// URIs are not supported, list the hosts with mongodb://")
+	}
+	if !strings.HasPrefix(uri, URI_PREFIX) {
+		return settings, fmt.Errorf("the URI must start with %s", URI_PREFIX)
+	}
+	rest := uri[len(URI_PREFIX):]
+
+	var query string
+	if i := strings.Index(rest, "?"); i >= 0 {
+		query = rest[i+1:]
+		rest = rest[:i]
+	}
+	if i := strings.Index(rest, "/"); i >= 0 {
+		db, err := url.PathUnescape(rest[i+1:])
+		if err != nil {
+			return settings, fmt.Errorf("invalid database: %s", err)
+		}
+		settings.Db = db
+		rest = rest[:i]
+	}
+	if i := strings.LastIndex(rest, "@"); i >= 0 {
+		userInfo := rest[:i]
+		rest = rest[i+1:]
+		password := ""
+		if j := strings.Index(userInfo, ":"); j >= 0 {
+			password = userInfo[j+1:]
+			userInfo = userInfo[:j]
+		}
+		username, err := url.PathUnescape(userInfo)
+		if err != nil {
+			return settings, fmt.Errorf("invalid username: %s", err)
+		}
+		if settings.Password, err = url.PathUnescape(password); err != nil {
+			return settings, fmt.Errorf("invalid password: %s", err)
+		}
+		settings.Username = username
+	}
+	for _, host := range strings.Split(rest, ",") {
+		if len(host) == 0 {
+			return settings, errors.New("empty host in URI")
+		}
+		settings.Host = append(settings.Host, host)
+	}
+
+	options, err := url.ParseQuery(query)
+	if err != nil {
+		return settings, fmt.Errorf("invalid options: %s", err)
+	}
+	for key, values := range options {
+		value := values[len(values)-1]
+		switch strings.ToLower(key) {
+		case "authsource":
+			settings.AuthenticationDatabase = value
+		case "authmechanism":
+			settings.AuthMechanism = value
+		case "replicaset":
+			settings.ReplicaSet = value
+		case "readpreference":
+			if _, ok := readPreferences[value]; !ok {
+				return settings, fmt.Errorf("unknown readPreference: %s", value)
+			}
+			settings.ReadPreference = value
+		case "ssl", "tls":
+			useSSL, err := strconv.ParseBool(value)
+			if err != nil {
+				return settings, fmt.Errorf("invalid %s option: %s", key, value)
+			}
+			settings.UseSSL = useSSL
+		case "tlscafile":
+			settings.TLSCAFile = value
+		case "tlscertificatekeyfile":
+			settings.TLSCertificateKeyFile = value
+		case "tlsinsecure", "tlsallowinvalidcertificates":
+			insecure, err := strconv.ParseBool(value)
+			if err != nil {
+				return settings, fmt.Errorf("invalid %s option: %s", key, value)
+			}
+			settings.TLSInsecure = insecure
+		case "connecttimeoutms":
+			ms, err := strconv.Atoi(value)
+			if err != nil {
+				return settings, fmt.Errorf("invalid connectTimeoutMS option: %s", value)
+			}
+			settings.Timeout = time.Duration(ms) * time.Millisecond
+		default:
+			if rejected, ok := rejectedOptions[strings.ToLower(key)]; ok && (len(rejected.harmless) == 0 || !strings.EqualFold(value, rejected.harmless)) {
+				return settings, fmt.Errorf("unsupported URI option %s: %s", key, rejected.reason)
+			}
+			log.Printf("Ignoring unsupported URI option: %s", key)
+		}
+	}
+
+	// The database of the URI is the authentication database unless authSource is given.
+	if len(settings.Username) > 0 && len(settings.AuthenticationDatabase) == 0 && settings.AuthMechanism != MECHANISM_X509 {
+		settings.AuthenticationDatabase = settings.Db
+	}
+	if settings.UsesTLSOptions() {
+		settings.UseSSL = true
+	}
+	return settings, nil
+}
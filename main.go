@@ -4,6 +4,12 @@ import (
 	"github.com/GwentAPI/manipulator/cmd"
 )
 
+// Set at build time with -ldflags "-X main.version=<version>"
+var version string
+
 func main() {
+	if len(version) > 0 {
+		cmd.Version = version
+	}
 	cmd.Execute()
 }
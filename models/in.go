@@ -1,5 +1,9 @@
 package models
 
+import (
+	"sort"
+)
+
 type GwentCard struct {
 	Categories []string
 	Faction    string
@@ -15,6 +19,16 @@ type GwentCard struct {
 	Variations map[string]GwentVariation
 }
 
+// VariationKeys returns the keys of the variations in a stable order, used to number them.
+func (c GwentCard) VariationKeys() []string {
+	keys := make([]string, 0, len(c.Variations))
+	for key := range c.Variations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 type GwentVariation struct {
 	Art          GwentArt
 	Availability string
@@ -0,0 +1,22 @@
+package models
+
+// GwentCardV2 is a card of the second version of the definition files:
+// the ability replaces Info, the tier of the card (Bronze, Silver, Gold or Leader)
+// is named color and type is the kind of the card (unit, special, artifact...).
+type GwentCardV2 struct {
+	Ability    map[string]string
+	Armor      int
+	Categories []string
+	Color      string
+	Faction    string
+	Flavor     map[string]string
+	IngameId   string
+	Loyalties  []string
+	Name       map[string]string
+	Positions  []string
+	Provision  int
+	Released   bool
+	Strength   int
+	Type       string
+	Variations map[string]GwentVariation
+}
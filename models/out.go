@@ -25,6 +25,7 @@ type Card struct {
 	Faction_id    bson.ObjectId     "faction_id,omitempty"
 	Group         string            "group"
 	Group_id      bson.ObjectId     "group_id,omitempty"
+	IngameId      string            "ingameId,omitempty"
 	Categories_id []bson.ObjectId   "categories_id,omitempty"
 	UUID          []byte            "uuid"
 	Last_Modified time.Time         "last_modified"
@@ -54,3 +55,15 @@ type Art struct {
 	MediumsizeImage string  "mediumsizeImage"
 	ThumbnailImage  string  "thumbnailImage"
 }
+
+type Alias struct {
+	ID            bson.ObjectId "_id,omitempty"
+	Collection    string        "collection"
+	Name          string        "name"
+	OldName       string        "old_name,omitempty"
+	OldUUID       []byte        "old_uuid"
+	NewUUID       []byte        "new_uuid"
+	OldArtSlug    string        "old_art_slug,omitempty"
+	NewArtSlug    string        "new_art_slug,omitempty"
+	Last_Modified time.Time     "last_modified"
+}
@@ -0,0 +1,128 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/GwentAPI/manipulator/models"
+	"reflect"
+	"strings"
+)
+
+// Versions of the card definition files.
+const (
+	VERSION_AUTO string = "auto"
+	VERSION_1    string = "v1"
+	VERSION_2    string = "v2"
+)
+
+// Fields only found in the cards of each version.
+var versionFields = map[string][]string{
+	VERSION_1: {"Info"},
+	VERSION_2: {"Ability", "Armor", "Color", "Provision"},
+}
+
+// Names of the fields of the version 1 in the cards of the version 2.
+var v2Names = map[string]string{"Info": "Ability", "Type": "Color"}
+
+// Detect returns the version of a card from its fields: a card with a field only found
+// in the version 2 is a v2 card, any other card a v1 card.
+func Detect(card []byte) (string, error) {
+	field, err := findVersionField(card, VERSION_2)
+	if err != nil {
+		return "", err
+	}
+	if len(field) > 0 {
+		return VERSION_2, nil
+	}
+	return VERSION_1, nil
+}
+
+// Check returns an error if the card has a field only found in another version than the given one.
+// A card that isn't a JSON object doesn't have any field: its error is left to Decode.
+func Check(version string, card []byte) error {
+	for other := range versionFields {
+		if other == version {
+			continue
+		}
+		field, err := findVersionField(card, other)
+		if err != nil {
+			return nil
+		}
+		if len(field) > 0 {
+			return fmt.Errorf("field %s of schema %s in a %s card", field, other, version)
+		}
+	}
+	return nil
+}
+
+// findVersionField returns a field of the card only found in the version, or an empty string.
+// The names are matched without regard to case, as encoding/json does.
+func findVersionField(card []byte, version string) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(card, &fields); err != nil {
+		return "", err
+	}
+	for field := range fields {
+		for _, versionField := range versionFields[version] {
+			if strings.EqualFold(field, versionField) {
+				return field, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// FieldName returns the name, in the cards of a version, of a field named as in the version 1,
+// e.g. Info is named Ability in the version 2.
+func FieldName(version, field string) string {
+	if name, ok := v2Names[field]; ok && version == VERSION_2 {
+		return name
+	}
+	return field
+}
+
+// Model returns the input model of a version, whose fields are the known fields of a card.
+func Model(version string) (reflect.Type, error) {
+	switch version {
+	case VERSION_1:
+		return reflect.TypeOf(models.GwentCard{}), nil
+	case VERSION_2:
+		return reflect.TypeOf(models.GwentCardV2{}), nil
+	}
+	return nil, fmt.Errorf("Unknown schema version: %s", version)
+}
+
+// Decode decodes a card of the given version into the canonical model, models.GwentCard.
+func Decode(version string, card []byte) (models.GwentCard, error) {
+	switch version {
+	case VERSION_1:
+		var v1 models.GwentCard
+		err := json.Unmarshal(card, &v1)
+		return v1, err
+	case VERSION_2:
+		var v2 models.GwentCardV2
+		if err := json.Unmarshal(card, &v2); err != nil {
+			return models.GwentCard{}, err
+		}
+		return fromV2(v2), nil
+	}
+	return models.GwentCard{}, fmt.Errorf("Unknown schema version: %s", version)
+}
+
+// fromV2 converts a card of the version 2. Its kind, provision and armor aren't kept.
+func fromV2(card models.GwentCardV2) models.GwentCard {
+	return models.GwentCard{
+		Categories: card.Categories,
+		Faction:    card.Faction,
+		Flavor:     card.Flavor,
+		Info:       card.Ability,
+		IngameId:   card.IngameId,
+		Loyalties:  card.Loyalties,
+		Name:       card.Name,
+		Positions:  card.Positions,
+		Released:   card.Released,
+		Strength:   card.Strength,
+		Group:      card.Color,
+		Variations: card.Variations,
+	}
+}
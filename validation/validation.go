@@ -0,0 +1,315 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/GwentAPI/manipulator/models"
+	"github.com/GwentAPI/manipulator/schema"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Severities of a finding.
+const (
+	SEVERITY_ERROR   string = "error"
+	SEVERITY_WARNING string = "warning"
+)
+
+// Finding is a problem found in a card definition file.
+type Finding struct {
+	// JSON path of the value, e.g. $["123"].Name["en-US"]
+	Path     string `json:"path"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s %s: %s", f.Severity, f.Path, f.Message)
+}
+
+// Rules are the values accepted for the enumerated fields.
+type Rules struct {
+	Factions       []string
+	Types          []string
+	Rarities       []string
+	Availabilities []string
+}
+
+// DefaultRules are the values found in the definition files of Gwent, once normalized.
+var DefaultRules = Rules{
+	Factions:       []string{"Monster", "Neutral", "Nilfgaard", "Northern Realms", "Scoia'tael", "Skellige"},
+	Types:          []string{"Bronze", "Gold", "Leader", "Silver"},
+	Rarities:       []string{"Common", "Epic", "Legendary", "Rare"},
+	Availabilities: []string{"BaseSet", "NonOwnable", "Tutorial"},
+}
+
+// HasErrors returns true if one of the findings is an error.
+func HasErrors(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == SEVERITY_ERROR {
+			return true
+		}
+	}
+	return false
+}
+
+// Validator checks a card definition card by card, as it is read. The checks across cards,
+// the translations and the IngameIds, only concern the cards recorded by RecordCard and
+// are run by Finish once every card is read.
+type Validator struct {
+	rules    Rules
+	findings []Finding
+	errors   int
+	// Locales of the translated texts of each card, by card key and field.
+	locales map[string]map[string][]string
+	// Schema version of each card, to name its fields.
+	versions  map[string]string
+	ingameIds map[string][]string
+}
+
+func NewValidator(rules Rules) *Validator {
+	return &Validator{
+		rules:     rules,
+		locales:   make(map[string]map[string][]string),
+		versions:  make(map[string]string),
+		ingameIds: make(map[string][]string),
+	}
+}
+
+// HasErrors returns true if an error was found in the cards checked so far.
+func (v *Validator) HasErrors() bool {
+	return v.errors > 0
+}
+
+func (v *Validator) add(path, severity, format string, args ...interface{}) {
+	if severity == SEVERITY_ERROR {
+		v.errors++
+	}
+	v.findings = append(v.findings, Finding{Path: path, Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+// CheckCard checks a card, released or not: the fields of raw, the card as found in the definition,
+// must be known for its schema version, and the values of card, its content in the canonical model, valid.
+func (v *Validator) CheckCard(key string, raw []byte, version string, card models.GwentCard) {
+	path := cardPath(key)
+	model, err := schema.Model(version)
+	if err != nil {
+		v.add(path, SEVERITY_ERROR, "%s", err)
+		return
+	}
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		v.add(path, SEVERITY_ERROR, "%s", err)
+		return
+	}
+	checkFields(path, value, model, v)
+	v.checkValues(key, version, card)
+}
+
+// RecordCard records the translations and the IngameId of a card kept by the release filter,
+// for the checks of Finish.
+func (v *Validator) RecordCard(key, version string, card models.GwentCard) {
+	if len(card.IngameId) > 0 {
+		v.ingameIds[card.IngameId] = append(v.ingameIds[card.IngameId], key)
+	}
+	v.versions[key] = version
+	v.locales[key] = make(map[string][]string)
+	for field, texts := range cardTexts(card) {
+		for locale := range texts {
+			v.locales[key][field] = append(v.locales[key][field], locale)
+		}
+	}
+}
+
+// InvalidCard reports a card which couldn't be decoded.
+func (v *Validator) InvalidCard(key string, err error) {
+	v.add(cardPath(key), SEVERITY_ERROR, "%s", err)
+}
+
+// Finish runs the checks across cards and returns every finding, sorted by path.
+func (v *Validator) Finish() []Finding {
+	v.checkTranslations()
+	for _, ingameId := range sortedKeys(v.ingameIds) {
+		if duplicates := v.ingameIds[ingameId]; len(duplicates) > 1 {
+			for _, key := range duplicates {
+				v.add(cardPath(key)+".IngameId", SEVERITY_ERROR, "IngameId %s is used by %d cards", ingameId, len(duplicates))
+			}
+		}
+	}
+	sort.SliceStable(v.findings, func(i, j int) bool {
+		return v.findings[i].Path < v.findings[j].Path
+	})
+	return v.findings
+}
+
+// checkFields reports the fields of the JSON value that don't match a field of the type.
+// As encoding/json, the names are matched without regard to case.
+func checkFields(path string, value interface{}, typ reflect.Type, v *Validator) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for _, key := range sortedKeys(object) {
+			field, ok := findField(typ, key)
+			if !ok {
+				v.add(path+"."+key, SEVERITY_ERROR, "unknown field")
+				continue
+			}
+			checkFields(path+"."+key, object[key], field.Type, v)
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for _, key := range sortedKeys(object) {
+			checkFields(fmt.Sprintf("%s[%q]", path, key), object[key], typ.Elem(), v)
+		}
+	case reflect.Slice:
+		array, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		for i, element := range array {
+			checkFields(fmt.Sprintf("%s[%d]", path, i), element, typ.Elem(), v)
+		}
+	}
+}
+
+func findField(typ reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if len(name) == 0 {
+			name = field.Name
+		}
+		if strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// checkValues checks the values of a card. The fields are named as in the schema version of the card.
+func (v *Validator) checkValues(key, version string, card models.GwentCard) {
+	path := cardPath(key)
+	if len(card.Name["en-US"]) == 0 {
+		v.add(path+`.Name["en-US"]`, SEVERITY_ERROR, "missing English name")
+	}
+	if !contains(v.rules.Factions, card.Faction) {
+		v.add(path+".Faction", SEVERITY_ERROR, "unknown faction %q", card.Faction)
+	}
+	if !contains(v.rules.Types, card.Group) {
+		v.add(path+"."+schema.FieldName(version, "Type"), SEVERITY_ERROR, "unknown type %q", card.Group)
+	}
+	for _, variationKey := range card.VariationKeys() {
+		variation := card.Variations[variationKey]
+		variationPath := fmt.Sprintf("%s.Variations[%q]", path, variationKey)
+		if !contains(v.rules.Rarities, variation.Rarity) {
+			v.add(variationPath+".Rarity", SEVERITY_ERROR, "unknown rarity %q", variation.Rarity)
+		}
+		if !contains(v.rules.Availabilities, variation.Availability) {
+			v.add(variationPath+".Availability", SEVERITY_WARNING, "unknown availability %q", variation.Availability)
+		}
+		for name, cost := range map[string]int{
+			"Craft.Standard": variation.Craft.Standard,
+			"Craft.Premium":  variation.Craft.Premium,
+			"Mill.Standard":  variation.Mill.Standard,
+			"Mill.Premium":   variation.Mill.Premium,
+		} {
+			if cost < 0 {
+				v.add(variationPath+"."+name, SEVERITY_ERROR, "negative cost %d", cost)
+			}
+		}
+		for name, link := range map[string]string{
+			"Original":  variation.Art.Original,
+			"High":      variation.Art.High,
+			"Medium":    variation.Art.Medium,
+			"Low":       variation.Art.Low,
+			"Thumbnail": variation.Art.Thumbnail,
+		} {
+			if len(link) > 0 && !isURL(link) {
+				v.add(variationPath+".Art."+name, SEVERITY_WARNING, "invalid URL %q", link)
+			}
+		}
+	}
+}
+
+// checkTranslations reports the texts missing a locale used by the same field of another card.
+func (v *Validator) checkTranslations() {
+	used := make(map[string]map[string]struct{})
+	for _, fields := range v.locales {
+		for field, locales := range fields {
+			if used[field] == nil {
+				used[field] = make(map[string]struct{})
+			}
+			for _, locale := range locales {
+				used[field][locale] = struct{}{}
+			}
+		}
+	}
+	for _, key := range sortedKeys(v.locales) {
+		for _, field := range []string{"Name", "Info", "Flavor"} {
+			locales := v.locales[key][field]
+			// Info and Flavor are optional, but should be translated when present.
+			if len(locales) == 0 && field != "Name" {
+				continue
+			}
+			present := make(map[string]struct{}, len(locales))
+			for _, locale := range locales {
+				present[locale] = struct{}{}
+			}
+			var missing []string
+			for locale := range used[field] {
+				// A missing English name is already an error.
+				if _, ok := present[locale]; !ok && !(field == "Name" && locale == "en-US") {
+					missing = append(missing, locale)
+				}
+			}
+			if len(missing) > 0 {
+				sort.Strings(missing)
+				v.add(cardPath(key)+"."+schema.FieldName(v.versions[key], field), SEVERITY_WARNING, "missing locales: %s", strings.Join(missing, ", "))
+			}
+		}
+	}
+}
+
+// cardTexts returns the translated texts of a card by field.
+func cardTexts(card models.GwentCard) map[string]map[string]string {
+	return map[string]map[string]string{"Name": card.Name, "Info": card.Info, "Flavor": card.Flavor}
+}
+
+func cardPath(key string) string {
+	return fmt.Sprintf("$[%q]", key)
+}
+
+func isURL(link string) bool {
+	u, err := url.Parse(link)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && len(u.Host) > 0
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m interface{}) []string {
+	value := reflect.ValueOf(m)
+	keys := make([]string, 0, value.Len())
+	for _, key := range value.MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return keys
+}